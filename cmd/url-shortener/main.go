@@ -1,29 +1,237 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
+	"url-shortener/internal/clicks"
 	"url-shortener/internal/config"
+	"url-shortener/internal/domainpolicy"
+	"url-shortener/internal/http-server/middleware/auth"
 	"url-shortener/internal/http-server/routes"
 	"url-shortener/internal/http-server/server"
+	"url-shortener/internal/janitor"
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/oidc"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/ssrf"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
 	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/storage/sqlite"
 )
 
 func main() {
 	cfg := config.MustLoad()
 	log := config.SetupLogger(cfg.Env)
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Error("migration failed", sl.Err(err))
+			os.Exit(1)
+		}
+		return
+	}
+
 	log.Info("starting url-shortener", slog.String("env", cfg.Env))
 
-	storage, err := postgres.New(cfg.StoragePath)
+	store, err := setupStorage(cfg.Storage)
 	if err != nil {
 		log.Error("failed init storage", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 
-	log.Info("connecting to PostgreSQL database", slog.String("storage_path", cfg.StoragePath))
-	
-	router := routes.SetupRouter(log, storage, cfg)
+	log.Info("storage initialized", slog.String("driver", cfg.Storage.Driver))
+
+	if cfg.Storage.AutoMigrate {
+		if err := migrateUp(context.Background(), log, store); err != nil {
+			log.Error("failed to migrate storage", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	if err := ensureAdmin(store, cfg.HTTPServer.User); err != nil {
+		log.Error("failed to set up admin user", sl.Err(err))
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go janitor.New(log, store, cfg.Janitor.Interval, cfg.Janitor.BatchSize).Run(ctx)
+
+	generator, err := alias.NewGenerator(cfg.Alias.Generator, cfg.Alias.Salt)
+	if err != nil {
+		log.Error("failed to init alias generator", sl.Err(err))
+		os.Exit(1)
+	}
+
+	policy, err := alias.NewPolicy(alias.PolicyOptions{
+		Charset:       cfg.Alias.Custom.Charset,
+		MinLength:     cfg.Alias.Custom.MinLength,
+		MaxLength:     cfg.Alias.Custom.MaxLength,
+		CaseSensitive: cfg.Alias.Custom.CaseSensitive,
+		Reserved:      cfg.Alias.Custom.Reserved,
+	})
+	if err != nil {
+		log.Error("failed to init alias policy", sl.Err(err))
+		os.Exit(1)
+	}
+
+	aliases := alias.New(log, alias.Options{
+		Generator:     generator,
+		Policy:        policy,
+		Length:        cfg.AliasLength,
+		MaxLength:     cfg.Alias.MaxLength,
+		MaxAttempts:   cfg.Alias.MaxAttempts,
+		GrowThreshold: cfg.Alias.GrowThreshold,
+		GrowWindow:    cfg.Alias.GrowWindow,
+	})
+
+	destinations, err := domainpolicy.New(log, cfg.DomainPolicy.File)
+	if err != nil {
+		log.Error("failed to load domain policy", sl.Err(err))
+		os.Exit(1)
+	}
+
+	go destinations.Run(ctx, cfg.DomainPolicy.ReloadInterval)
+
+	selfHosts := cfg.SSRF.SelfHosts
+	if host, _, err := net.SplitHostPort(cfg.HTTPServer.Address); err == nil && host != "" {
+		selfHosts = append(selfHosts, host)
+	}
+
+	guard, err := ssrf.New(log, ssrf.Options{
+		Schemes:        cfg.SSRF.Schemes,
+		SelfHosts:      selfHosts,
+		TrustedHosts:   cfg.SSRF.TrustedHosts,
+		ResolveTimeout: cfg.SSRF.ResolveTimeout,
+	})
+	if err != nil {
+		log.Error("failed to init destination guard", sl.Err(err))
+		os.Exit(1)
+	}
+
+	pipeline := clicks.New(log, store, clicks.Options{
+		BufferSize:    cfg.Clicks.BufferSize,
+		Workers:       cfg.Clicks.Workers,
+		BatchSize:     cfg.Clicks.BatchSize,
+		FlushInterval: cfg.Clicks.FlushInterval,
+	})
+
+	tokens, err := setupTokenVerifier(ctx, log, cfg.OIDC)
+	if err != nil {
+		log.Error("failed to init sso token verifier", sl.Err(err))
+		os.Exit(1)
+	}
+
+	router := routes.SetupRouter(log, store, pipeline, aliases, destinations, guard, tokens, cfg)
+
+	server.Start(ctx, log, cfg, router)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
+
+	if err := pipeline.Close(shutdownCtx); err != nil {
+		log.Error("failed to flush clicks", sl.Err(err))
+	}
+
+	if err := store.Close(); err != nil {
+		log.Error("failed to close storage", sl.Err(err))
+	}
+}
+
+func setupStorage(cfg config.Storage) (storage.Storage, error) {
+	switch cfg.Driver {
+	case "postgres":
+		return postgres.New(cfg.Path)
+	case "sqlite":
+		return sqlite.New(cfg.Path)
+	case "memory":
+		return memory.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+}
+
+// setupTokenVerifier returns the verifier of SSO tokens, or nil when no JWKS
+// is configured. The keys are reloaded in the background until ctx is done.
+func setupTokenVerifier(ctx context.Context, log *slog.Logger, cfg config.OIDC) (auth.TokenVerifier, error) {
+	if cfg.JWKSFile == "" && cfg.JWKSURL == "" {
+		return nil, nil
+	}
+
+	roles := make(map[string]rbac.Role, len(cfg.Roles))
+	for group, name := range cfg.Roles {
+		role, err := rbac.Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("role of group %q: %w", group, err)
+		}
+		roles[group] = role
+	}
+
+	var defaultRole rbac.Role
+	if cfg.DefaultRole != "" {
+		role, err := rbac.Parse(cfg.DefaultRole)
+		if err != nil {
+			return nil, fmt.Errorf("default role: %w", err)
+		}
+		defaultRole = role
+	}
+
+	keys, err := oidc.NewKeySet(ctx, log, cfg.JWKSFile, cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	go keys.Run(ctx, cfg.JWKSRefresh)
+
+	return oidc.NewVerifier(keys, oidc.Options{
+		Issuer:      cfg.Issuer,
+		Audience:    cfg.Audience,
+		UserClaim:   cfg.UserClaim,
+		GroupsClaim: cfg.GroupsClaim,
+		Roles:       roles,
+		DefaultRole: defaultRole,
+		Leeway:      cfg.Leeway,
+	}), nil
+}
+
+// ensureAdmin creates the user the basic auth account signs in as, so links
+// created with it have an owner. The account acts as an admin whatever the
+// role of an existing user says. Creating the user is recorded in the audit
+// log on behalf of the system.
+func ensureAdmin(store storage.Storage, name string) error {
+	user, err := store.GetUserByName(name)
+	if err == nil && user.SSO() {
+		return fmt.Errorf("basic auth user %q is an SSO user", name)
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return err
+	}
+
+	user = storage.User{Name: name, Role: string(rbac.RoleAdmin)}
+
+	after, err := json.Marshal(map[string]string{"name": user.Name, "role": user.Role})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.CreateUser(user, storage.AuditEntry{
+		Actor:  storage.SystemActor,
+		Action: storage.ActionUserCreate,
+		After:  string(after),
+	})
+	if errors.Is(err, storage.ErrUserExists) {
+		return nil
+	}
 
-	server.Start(log, cfg, router)
+	return err
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"url-shortener/internal/config"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/migrate"
+)
+
+const migrateUsage = "usage: url-shortener migrate up|down|status"
+
+// migratable is implemented by storages backed by an SQL schema.
+type migratable interface {
+	Migrator() (*migrate.Migrator, error)
+}
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	store, err := setupStorage(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	m, ok := store.(migratable)
+	if !ok {
+		return fmt.Errorf("storage driver %s does not support migrations", cfg.Storage.Driver)
+	}
+
+	migrator, err := m.Migrator()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		mig, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %04d_%s\n", mig.Version, mig.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range statuses {
+			appliedAt := "pending"
+			if st.Applied {
+				appliedAt = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
+
+func migrateUp(ctx context.Context, log *slog.Logger, store storage.Storage) error {
+	m, ok := store.(migratable)
+	if !ok {
+		return nil
+	}
+
+	migrator, err := m.Migrator()
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx)
+	for _, mig := range applied {
+		log.Info("migration applied", slog.Int64("version", mig.Version), slog.String("name", mig.Name))
+	}
+
+	return err
+}
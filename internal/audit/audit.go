@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Entry returns the audit entry of action made on behalf of the principal of
+// c. before and after are stored as JSON, nil stands for an object that did
+// not exist before or after the action. The entry is passed to the storage
+// operation making the change, which appends it in the same transaction.
+func Entry(c *gin.Context, action, alias string, before, after any) (storage.AuditEntry, error) {
+	const op = "audit.Entry"
+
+	principal := auth.FromContext(c)
+
+	entry := storage.AuditEntry{
+		Actor:     principal.Name,
+		ActorID:   principal.UserID,
+		Action:    action,
+		Alias:     alias,
+		RequestID: c.GetString("request_id"),
+		ClientIP:  c.ClientIP(),
+	}
+
+	var err error
+
+	if entry.Before, err = encode(before); err != nil {
+		return storage.AuditEntry{}, fmt.Errorf("%s: encode before: %w", op, err)
+	}
+	if entry.After, err = encode(after); err != nil {
+		return storage.AuditEntry{}, fmt.Errorf("%s: encode after: %w", op, err)
+	}
+
+	return entry, nil
+}
+
+func encode(v any) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,85 @@
+package audit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/audit"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	link := storage.Link{
+		Alias:          "docs",
+		URL:            "https://example.com/docs",
+		Owner:          "pedro",
+		PasswordHash:   "$2a$10$secret",
+		RedirectStatus: http.StatusMovedPermanently,
+	}
+
+	cases := []struct {
+		name   string
+		before any
+		after  any
+		entry  storage.AuditEntry
+	}{
+		{
+			name:  "Create",
+			after: link.AuditState(),
+			entry: storage.AuditEntry{
+				After: `{"alias":"docs","url":"https://example.com/docs","owner":"pedro","password_protected":true,"redirect_status":301}`,
+			},
+		},
+		{
+			name:   "Delete",
+			before: link.AuditState(),
+			entry: storage.AuditEntry{
+				Before: `{"alias":"docs","url":"https://example.com/docs","owner":"pedro","password_protected":true,"redirect_status":301}`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			want := tc.entry
+			want.Actor = "pedro"
+			want.ActorID = 5
+			want.Action = storage.ActionLinkCreate
+			want.Alias = "docs"
+			want.RequestID = "req-1"
+			want.ClientIP = "192.0.2.1"
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+			c.Request.RemoteAddr = "192.0.2.1:4321"
+			c.Set("request_id", "req-1")
+			auth.SetPrincipal(c, auth.Principal{UserID: 5, Name: "pedro", Role: rbac.RoleEditor})
+
+			entry, err := audit.Entry(c, storage.ActionLinkCreate, "docs", tc.before, tc.after)
+			require.NoError(t, err)
+			require.Equal(t, want, entry)
+		})
+	}
+}
+
+func TestEntry_Unencodable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+
+	_, err := audit.Entry(c, storage.ActionLinkCreate, "docs", nil, func() {})
+	require.Error(t, err)
+}
@@ -0,0 +1,175 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+)
+
+var (
+	ErrClosed = errors.New("click pipeline is closed")
+)
+
+type BatchSaver interface {
+	SaveClicks(clicks []storage.Click) error
+}
+
+type Options struct {
+	BufferSize    int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Pipeline buffers click events in memory and writes them to storage in
+// batches from a pool of workers, so redirects never wait for the database.
+type Pipeline struct {
+	log   *slog.Logger
+	saver BatchSaver
+	opts  Options
+
+	mu     sync.RWMutex
+	closed bool
+	events chan storage.Click
+	wg     sync.WaitGroup
+
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// Defaults used by New in place of options that are not positive.
+const (
+	DefaultBufferSize    = 10000
+	DefaultWorkers       = 2
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = time.Second
+)
+
+func New(log *slog.Logger, saver BatchSaver, opts Options) *Pipeline {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+
+	p := &Pipeline{
+		log:    log.With(slog.String("component", "clicks")),
+		saver:  saver,
+		opts:   opts,
+		events: make(chan storage.Click, opts.BufferSize),
+	}
+
+	p.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go p.worker()
+	}
+
+	return p
+}
+
+// RecordClick enqueues the click without blocking. When the buffer is full
+// the click is dropped and counted instead.
+func (p *Pipeline) RecordClick(click storage.Click) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	select {
+	case p.events <- click:
+	default:
+		p.dropped.Add(1)
+	}
+
+	return nil
+}
+
+// Dropped returns the number of clicks discarded because the buffer was full.
+func (p *Pipeline) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+// Failed returns the number of clicks lost because storage rejected the batch.
+func (p *Pipeline) Failed() int64 {
+	return p.failed.Load()
+}
+
+// Close stops accepting clicks and waits until the buffered ones are flushed
+// or ctx is done.
+func (p *Pipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.events)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if dropped := p.Dropped(); dropped > 0 {
+			p.log.Warn("clicks dropped under backpressure", slog.Int64("dropped", dropped))
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pipeline) worker() {
+	defer p.wg.Done()
+
+	batch := make([]storage.Click, 0, p.opts.BatchSize)
+
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case click, ok := <-p.events:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+
+			batch = append(batch, click)
+			if len(batch) >= p.opts.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			p.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (p *Pipeline) flush(batch []storage.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := p.saver.SaveClicks(batch); err != nil {
+		p.failed.Add(int64(len(batch)))
+		p.log.Error("failed to save clicks", sl.Err(err), slog.Int("batch", len(batch)))
+	}
+}
@@ -0,0 +1,148 @@
+package clicks_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/internal/clicks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+type saver struct {
+	mu      sync.Mutex
+	batches [][]storage.Click
+	block   chan struct{}
+	err     error
+}
+
+func (s *saver) SaveClicks(clicks []storage.Click) error {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batches = append(s.batches, append([]storage.Click(nil), clicks...))
+
+	return s.err
+}
+
+func (s *saver) total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for _, batch := range s.batches {
+		n += len(batch)
+	}
+
+	return n
+}
+
+func TestPipeline_FlushOnBatchSize(t *testing.T) {
+	s := &saver{}
+	p := clicks.New(slogdiscard.NewDiscardLogger(), s, clicks.Options{
+		BufferSize:    10,
+		Workers:       1,
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+	})
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.RecordClick(storage.Click{Alias: "a"}))
+	}
+
+	require.Eventually(t, func() bool { return s.total() == 3 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, p.Close(context.Background()))
+	require.Len(t, s.batches, 1)
+}
+
+func TestPipeline_FlushOnInterval(t *testing.T) {
+	s := &saver{}
+	p := clicks.New(slogdiscard.NewDiscardLogger(), s, clicks.Options{
+		BufferSize:    10,
+		Workers:       1,
+		BatchSize:     100,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	defer func() { _ = p.Close(context.Background()) }()
+
+	require.NoError(t, p.RecordClick(storage.Click{Alias: "a"}))
+
+	require.Eventually(t, func() bool { return s.total() == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestPipeline_FlushOnClose(t *testing.T) {
+	s := &saver{}
+	p := clicks.New(slogdiscard.NewDiscardLogger(), s, clicks.Options{
+		BufferSize:    10,
+		Workers:       2,
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+	})
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, p.RecordClick(storage.Click{Alias: "a"}))
+	}
+
+	require.NoError(t, p.Close(context.Background()))
+	require.Equal(t, 5, s.total())
+	require.ErrorIs(t, p.RecordClick(storage.Click{Alias: "a"}), clicks.ErrClosed)
+}
+
+func TestPipeline_DropsUnderBackpressure(t *testing.T) {
+	s := &saver{block: make(chan struct{})}
+	p := clicks.New(slogdiscard.NewDiscardLogger(), s, clicks.Options{
+		BufferSize:    2,
+		Workers:       1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+	})
+
+	// The worker blocks on the first batch, so the buffer fills up quickly.
+	var sent int
+	require.Eventually(t, func() bool {
+		_ = p.RecordClick(storage.Click{Alias: "a"})
+		sent++
+		return p.Dropped() > 0
+	}, time.Second, time.Millisecond)
+
+	close(s.block)
+	require.NoError(t, p.Close(context.Background()))
+	require.Equal(t, int64(sent), int64(s.total())+p.Dropped())
+}
+
+func TestPipeline_CountsFailedBatches(t *testing.T) {
+	s := &saver{err: errors.New("storage unavailable")}
+	p := clicks.New(slogdiscard.NewDiscardLogger(), s, clicks.Options{
+		BufferSize:    10,
+		Workers:       1,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+
+	require.NoError(t, p.RecordClick(storage.Click{Alias: "a"}))
+	require.NoError(t, p.RecordClick(storage.Click{Alias: "b"}))
+	require.NoError(t, p.Close(context.Background()))
+
+	require.Equal(t, int64(2), p.Failed())
+}
+
+func TestPipeline_Defaults(t *testing.T) {
+	s := &saver{}
+
+	// Without workers every click would stay in the buffer and a zero flush
+	// interval would panic, both fall back to the defaults.
+	p := clicks.New(slogdiscard.NewDiscardLogger(), s, clicks.Options{})
+
+	require.NoError(t, p.RecordClick(storage.Click{Alias: "a"}))
+	require.NoError(t, p.Close(context.Background()))
+	require.Equal(t, 1, s.total())
+}
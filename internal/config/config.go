@@ -2,6 +2,8 @@ package config
 
 import (
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"time"
 
@@ -10,19 +12,116 @@ import (
 )
 
 type Config struct {
-	Env         string `yaml:"env" env-default:"local"`
+	Env          string `yaml:"env" env-default:"local"`
+	GinMode      string `yaml:"gin_mode"`
+	AliasLength  int    `yaml:"alias_length" env-default:"8"`
+	Alias        `yaml:"alias"`
+	Normalize    `yaml:"normalize"`
+	DomainPolicy `yaml:"domain_policy"`
+	SSRF         `yaml:"ssrf"`
+	LinkPassword `yaml:"link_password"`
+	Redirect     `yaml:"redirect"`
+	Storage      `yaml:"storage"`
+	Janitor      `yaml:"janitor"`
+	Clicks       `yaml:"clicks"`
+	OIDC         `yaml:"oidc"`
+	HTTPServer   `yaml:"http_server"`
+	// StoragePath is the pre-driver location of the database, read into
+	// storage.path when that is not set.
+	//
+	// Deprecated: use storage.path.
 	StoragePath string `yaml:"storage_path"`
-	GinMode     string `yaml:"gin_mode"`
-	AliasLength int    `yaml:"alias_length" env-default:"8"`
-	HTTPServer  `yaml:"http_server"`
+}
+
+type Storage struct {
+	Driver      string `yaml:"driver" env-default:"postgres"`
+	Path        string `yaml:"path"`
+	AutoMigrate bool   `yaml:"auto_migrate" env-default:"true"`
+}
+
+type Alias struct {
+	Generator     string      `yaml:"generator" env-default:"random"`
+	Salt          string      `yaml:"salt"`
+	MaxLength     int         `yaml:"max_length" env-default:"16"`
+	MaxAttempts   int         `yaml:"max_attempts" env-default:"5"`
+	GrowThreshold float64     `yaml:"grow_threshold" env-default:"0.2"`
+	GrowWindow    int         `yaml:"grow_window" env-default:"100"`
+	Dedupe        bool        `yaml:"dedupe" env-default:"false"`
+	Custom        CustomAlias `yaml:"custom"`
+}
+
+type CustomAlias struct {
+	Charset       string   `yaml:"charset" env-default:"a-zA-Z0-9_-"`
+	MinLength     int      `yaml:"min_length" env-default:"3"`
+	MaxLength     int      `yaml:"max_length" env-default:"64"`
+	CaseSensitive bool     `yaml:"case_sensitive" env-default:"true"`
+	Reserved      []string `yaml:"reserved" env-default:"save,link,links,audit,keys,admin,users"`
+}
+
+type Normalize struct {
+	SortQuery  bool     `yaml:"sort_query" env-default:"false"`
+	DropParams []string `yaml:"drop_params" env-default:"utm_*,fbclid,gclid"`
+}
+
+type DomainPolicy struct {
+	File           string        `yaml:"file"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env-default:"10s"`
+}
+
+type SSRF struct {
+	Schemes        []string      `yaml:"schemes" env-default:"http,https"`
+	SelfHosts      []string      `yaml:"self_hosts"`
+	TrustedHosts   []string      `yaml:"trusted_hosts"`
+	ResolveTimeout time.Duration `yaml:"resolve_timeout" env-default:"2s"`
+}
+
+type LinkPassword struct {
+	MaxFailures int           `yaml:"max_failures" env-default:"5"`
+	LockWindow  time.Duration `yaml:"lock_window" env-default:"15m"`
+}
+
+type Redirect struct {
+	// DefaultStatus is used for links created without a redirect_status.
+	DefaultStatus int `yaml:"default_status" env-default:"302"`
+}
+
+type Janitor struct {
+	Interval  time.Duration `yaml:"interval" env-default:"1m"`
+	BatchSize int           `yaml:"batch_size" env-default:"500"`
+}
+
+type Clicks struct {
+	BufferSize    int           `yaml:"buffer_size" env-default:"10000"`
+	Workers       int           `yaml:"workers" env-default:"2"`
+	BatchSize     int           `yaml:"batch_size" env-default:"500"`
+	FlushInterval time.Duration `yaml:"flush_interval" env-default:"1s"`
+}
+
+// OIDC configures bearer JWTs issued by the company SSO. They are accepted
+// when JWKSFile or JWKSURL is set.
+type OIDC struct {
+	JWKSFile    string        `yaml:"jwks_file"`
+	JWKSURL     string        `yaml:"jwks_url"`
+	JWKSRefresh time.Duration `yaml:"jwks_refresh" env-default:"5m"`
+	Issuer      string        `yaml:"issuer"`
+	Audience    string        `yaml:"audience"`
+	UserClaim   string        `yaml:"user_claim" env-default:"sub"`
+	GroupsClaim string        `yaml:"groups_claim" env-default:"groups"`
+	// Roles maps SSO groups to roles, tokens get the most privileged role of
+	// their groups and DefaultRole when none of them is mapped.
+	Roles       map[string]string `yaml:"roles"`
+	DefaultRole string            `yaml:"default_role"`
+	Leeway      time.Duration     `yaml:"leeway" env-default:"30s"`
 }
 
 type HTTPServer struct {
-	Address     string        `yaml:"address" env-default:"localhost:5500"`
-	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
-	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
-	User        string        `yaml:"user" env-required:"true"`
-	Password    string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	Address         string        `yaml:"address" env-default:"localhost:5500"`
+	Timeout         time.Duration `yaml:"timeout" env-default:"4s"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
+	User            string        `yaml:"user" env-required:"true"`
+	Password        string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	TrustedProxies  []string      `yaml:"trusted_proxies"`
 }
 
 func MustLoad() *Config {
@@ -45,5 +144,26 @@ func MustLoad() *Config {
 		log.Fatalf("Cannot read config: %s", err)
 	}
 
+	if cfg.StoragePath != "" {
+		log.Printf("storage_path is deprecated, use storage.path")
+
+		if cfg.Storage.Path == "" {
+			cfg.Storage.Path = cfg.StoragePath
+		}
+	}
+
+	switch cfg.Redirect.DefaultStatus {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		log.Fatalf("Invalid redirect default status: %d", cfg.Redirect.DefaultStatus)
+	}
+
+	for _, proxy := range cfg.HTTPServer.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			log.Fatalf("Invalid trusted proxy: %s", proxy)
+		}
+	}
+
 	return &cfg
 }
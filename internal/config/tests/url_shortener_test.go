@@ -25,10 +25,13 @@ func TestURLShortener_HappyPath(t *testing.T) {
 	}
 	e := httpexpect.Default(t, u.String())
 
+	alias, err := random.NewRandomString(10)
+	require.NoError(t, err)
+
 	e.POST("/api/save").
 		WithJSON(save.Request{
 			URL:   gofakeit.URL(),
-			Alias: random.NewRandomString(10),
+			Alias: alias,
 		}).
 		WithBasicAuth("pedro", "d123").
 		Expect().
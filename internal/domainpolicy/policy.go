@@ -0,0 +1,263 @@
+package domainpolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/internal/lib/logger/sl"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AllowlistRule is reported as the matching rule for hosts rejected only
+// because they are missing from a non-empty allow list.
+const AllowlistRule = "allowlist"
+
+// Violation is returned by Check for hosts the policy rejects.
+type Violation struct {
+	Host string
+	// Rule is the rule as written in the policy file, or AllowlistRule.
+	Rule string
+}
+
+func (v *Violation) Error() string {
+	if v.Rule == AllowlistRule {
+		return fmt.Sprintf("host %s is not on the allowlist", v.Host)
+	}
+	return fmt.Sprintf("host %s is blocked by rule %q", v.Host, v.Rule)
+}
+
+// File is the format of the policy file.
+//
+// Rules are exact hosts ("example.com"), wildcards matching every subdomain
+// ("*.example.com", which does not match example.com itself) or regular
+// expressions enclosed in slashes ("/^login-.*\.com$/"). A host matching any
+// block rule is rejected. When Allow is not empty, hosts matching none of
+// its rules are rejected as well.
+type File struct {
+	Block []string `yaml:"block"`
+	Allow []string `yaml:"allow"`
+}
+
+// Policy decides which destination hosts links may point to. Rules are
+// reloaded from the policy file while Run is active, so Check always sees
+// a complete rule set. The zero value allows every host.
+type Policy struct {
+	log   *slog.Logger
+	path  string
+	rules atomic.Pointer[ruleSet]
+
+	modTime time.Time
+	size    int64
+}
+
+// New loads the policy from path. An empty path yields a policy that allows
+// every host.
+func New(log *slog.Logger, path string) (*Policy, error) {
+	const op = "domainpolicy.New"
+
+	p := &Policy{log: log, path: path}
+
+	if path == "" {
+		return p, nil
+	}
+
+	if err := p.Reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, nil
+}
+
+// FromFile builds a policy from already parsed rules, without a backing file.
+func FromFile(f File) (*Policy, error) {
+	const op = "domainpolicy.FromFile"
+
+	rules, err := compile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p := &Policy{}
+	p.rules.Store(rules)
+
+	return p, nil
+}
+
+// Check returns a *Violation when host may not be linked to.
+func (p *Policy) Check(host string) error {
+	if p == nil {
+		return nil
+	}
+
+	rules := p.rules.Load()
+	if rules == nil {
+		return nil
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if r, ok := rules.block.match(host); ok {
+		return &Violation{Host: host, Rule: r}
+	}
+
+	if len(rules.allow) > 0 {
+		if _, ok := rules.allow.match(host); !ok {
+			return &Violation{Host: host, Rule: AllowlistRule}
+		}
+	}
+
+	return nil
+}
+
+// Reload reads the policy file and swaps the rules in. On error the current
+// rules stay in effect.
+func (p *Policy) Reload() error {
+	const op = "domainpolicy.Reload"
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("%s: parse %s: %w", op, p.path, err)
+	}
+
+	rules, err := compile(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", op, p.path, err)
+	}
+
+	p.rules.Store(rules)
+	p.modTime, p.size = info.ModTime(), info.Size()
+
+	return nil
+}
+
+// Run reloads the policy file whenever its modification time or size changes,
+// checking every interval until ctx is done.
+func (p *Policy) Run(ctx context.Context, interval time.Duration) {
+	if p.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(p.path)
+		if err != nil {
+			p.log.Error("failed to stat domain policy", sl.Err(err))
+			continue
+		}
+
+		if info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+			continue
+		}
+
+		if err := p.Reload(); err != nil {
+			p.log.Error("failed to reload domain policy, keeping previous rules", sl.Err(err))
+			continue
+		}
+
+		rules := p.rules.Load()
+		p.log.Info("domain policy reloaded",
+			slog.Int("block_rules", len(rules.block)),
+			slog.Int("allow_rules", len(rules.allow)),
+		)
+	}
+}
+
+type ruleSet struct {
+	block rules
+	allow rules
+}
+
+type rule struct {
+	raw    string
+	exact  string
+	suffix string
+	re     *regexp.Regexp
+}
+
+type rules []rule
+
+func (rs rules) match(host string) (string, bool) {
+	for _, r := range rs {
+		switch {
+		case r.re != nil:
+			if r.re.MatchString(host) {
+				return r.raw, true
+			}
+		case r.suffix != "":
+			if strings.HasSuffix(host, r.suffix) {
+				return r.raw, true
+			}
+		case host == r.exact:
+			return r.raw, true
+		}
+	}
+
+	return "", false
+}
+
+func compile(f File) (*ruleSet, error) {
+	block, err := compileRules(f.Block)
+	if err != nil {
+		return nil, fmt.Errorf("block: %w", err)
+	}
+
+	allow, err := compileRules(f.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+
+	return &ruleSet{block: block, allow: allow}, nil
+}
+
+func compileRules(raw []string) (rules, error) {
+	rs := make(rules, 0, len(raw))
+
+	for _, s := range raw {
+		s = strings.TrimSpace(s)
+
+		switch {
+		case s == "":
+			return nil, errors.New("empty rule")
+		case len(s) > 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/"):
+			re, err := regexp.Compile(s[1 : len(s)-1])
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", s, err)
+			}
+			rs = append(rs, rule{raw: s, re: re})
+		case strings.HasPrefix(s, "*."):
+			rs = append(rs, rule{raw: s, suffix: strings.ToLower(s[1:])})
+		case strings.Contains(s, "*"):
+			return nil, fmt.Errorf("rule %q: wildcard is only allowed as the leftmost label", s)
+		default:
+			rs = append(rs, rule{raw: s, exact: strings.ToLower(strings.TrimSuffix(s, "."))})
+		}
+	}
+
+	return rs, nil
+}
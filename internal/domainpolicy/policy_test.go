@@ -0,0 +1,156 @@
+package domainpolicy_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"url-shortener/internal/domainpolicy"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	cases := []struct {
+		name string
+		file domainpolicy.File
+		host string
+		rule string
+	}{
+		{
+			name: "No rules",
+			host: "example.com",
+		},
+		{
+			name: "Exact host",
+			file: domainpolicy.File{Block: []string{"phish.example"}},
+			host: "phish.example",
+			rule: "phish.example",
+		},
+		{
+			name: "Exact host is case-insensitive",
+			file: domainpolicy.File{Block: []string{"Phish.Example"}},
+			host: "PHISH.example.",
+			rule: "Phish.Example",
+		},
+		{
+			name: "Exact host does not match subdomains",
+			file: domainpolicy.File{Block: []string{"phish.example"}},
+			host: "www.phish.example",
+		},
+		{
+			name: "Wildcard matches subdomains",
+			file: domainpolicy.File{Block: []string{"*.competitor.com"}},
+			host: "shop.eu.competitor.com",
+			rule: "*.competitor.com",
+		},
+		{
+			name: "Wildcard does not match the apex",
+			file: domainpolicy.File{Block: []string{"*.competitor.com"}},
+			host: "competitor.com",
+		},
+		{
+			name: "Wildcard does not match lookalikes",
+			file: domainpolicy.File{Block: []string{"*.competitor.com"}},
+			host: "notcompetitor.com",
+		},
+		{
+			name: "Regular expression",
+			file: domainpolicy.File{Block: []string{`/^login-[a-z]+\.(com|net)$/`}},
+			host: "login-bank.net",
+			rule: `/^login-[a-z]+\.(com|net)$/`,
+		},
+		{
+			name: "Allowlisted host",
+			file: domainpolicy.File{Allow: []string{"example.com", "*.example.com"}},
+			host: "docs.example.com",
+		},
+		{
+			name: "Host outside the allowlist",
+			file: domainpolicy.File{Allow: []string{"example.com"}},
+			host: "example.org",
+			rule: domainpolicy.AllowlistRule,
+		},
+		{
+			name: "Block wins over allow",
+			file: domainpolicy.File{
+				Block: []string{"evil.example.com"},
+				Allow: []string{"*.example.com"},
+			},
+			host: "evil.example.com",
+			rule: "evil.example.com",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := domainpolicy.FromFile(tc.file)
+			require.NoError(t, err)
+
+			err = p.Check(tc.host)
+			if tc.rule == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var v *domainpolicy.Violation
+			require.True(t, errors.As(err, &v))
+			require.Equal(t, tc.rule, v.Rule)
+		})
+	}
+}
+
+func TestPolicy_InvalidRules(t *testing.T) {
+	for _, f := range []domainpolicy.File{
+		{Block: []string{"/[/"}},
+		{Block: []string{"foo.*.com"}},
+		{Allow: []string{" "}},
+	} {
+		_, err := domainpolicy.FromFile(f)
+		require.Error(t, err, f)
+	}
+}
+
+func TestPolicy_NoFile(t *testing.T) {
+	p, err := domainpolicy.New(slogdiscard.NewDiscardLogger(), "")
+	require.NoError(t, err)
+	require.NoError(t, p.Check("anything.example"))
+
+	_, err = domainpolicy.New(slogdiscard.NewDiscardLogger(), filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestPolicy_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("block:\n  - old.example\n"), 0o600))
+
+	p, err := domainpolicy.New(slogdiscard.NewDiscardLogger(), path)
+	require.NoError(t, err)
+	require.Error(t, p.Check("old.example"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go p.Run(ctx, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte("block:\n  - new.example\n  - '*.new.example'\n"), 0o600))
+
+	require.Eventually(t, func() bool {
+		return p.Check("old.example") == nil && p.Check("new.example") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// A broken file keeps the previous rules in effect.
+	require.NoError(t, os.WriteFile(path, []byte("block:\n  - '/[/'\n"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+
+	require.Error(t, p.Check("a.new.example"))
+	require.NoError(t, p.Check("old.example"))
+}
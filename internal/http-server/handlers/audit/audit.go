@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const defaultLimit = 50
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+type Request struct {
+	Cursor string     `form:"cursor"`
+	Limit  int        `form:"limit" validate:"omitempty,min=1,max=200"`
+	Actor  string     `form:"actor"`
+	Action string     `form:"action"`
+	Alias  string     `form:"alias"`
+	Since  *time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until  *time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+type Entry struct {
+	ID        int64           `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Alias     string          `json:"alias,omitempty"`
+	TargetID  int64           `json:"target_id,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	ClientIP  string          `json:"client_ip,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Entries    []Entry `json:"entries"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=AuditLister
+type AuditLister interface {
+	ListAudit(filter storage.AuditFilter) ([]storage.AuditEntry, error)
+}
+
+// New lists the audit log, newest entries first.
+func New(log *slog.Logger, auditLister AuditLister) gin.HandlerFunc {
+	validate := validator.New()
+
+	return func(c *gin.Context) {
+		const op = "handlers.audit.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+		)
+
+		var req Request
+		if err := c.ShouldBindQuery(&req); err != nil {
+			log.Error("failed to decode query", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error("failed to decode request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.ValidationError(validateErr))
+			return
+		}
+
+		filter := storage.AuditFilter{
+			Actor:  req.Actor,
+			Action: req.Action,
+			Alias:  req.Alias,
+			Since:  req.Since,
+			Until:  req.Until,
+			Limit:  defaultLimit,
+		}
+
+		if req.Limit != 0 {
+			filter.Limit = req.Limit
+		}
+
+		if req.Cursor != "" {
+			id, err := decodeCursor(req.Cursor)
+			if err != nil {
+				log.Info("invalid cursor", sl.Err(err))
+				c.JSON(http.StatusBadRequest, resp.Error(ErrInvalidCursor.Error()))
+				return
+			}
+
+			filter.BeforeID = id
+		}
+
+		// One extra entry tells whether there is a next page.
+		limit := filter.Limit
+		filter.Limit++
+
+		entries, err := auditLister.ListAudit(filter)
+		if err != nil {
+			log.Error("failed to list audit log", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to list audit log"))
+			return
+		}
+
+		res := Response{
+			Response: resp.OK(),
+			Entries:  make([]Entry, 0, len(entries)),
+		}
+
+		if len(entries) > limit {
+			entries = entries[:limit]
+			res.NextCursor = encodeCursor(entries[len(entries)-1].ID)
+		}
+
+		for _, e := range entries {
+			res.Entries = append(res.Entries, Entry{
+				ID:        e.ID,
+				CreatedAt: e.CreatedAt,
+				Actor:     e.Actor,
+				Action:    e.Action,
+				Alias:     e.Alias,
+				TargetID:  e.TargetID,
+				Before:    rawJSON(e.Before),
+				After:     rawJSON(e.After),
+				RequestID: e.RequestID,
+				ClientIP:  e.ClientIP,
+			})
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(encoded string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
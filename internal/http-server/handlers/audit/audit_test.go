@@ -0,0 +1,195 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/audit"
+	"url-shortener/internal/http-server/handlers/audit/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	entries := []storage.AuditEntry{
+		{ID: 3, Actor: "pedro", Action: "link.delete", Alias: "a", Before: `{"alias":"a"}`},
+		{ID: 2, Actor: "maria", Action: "key.create", After: `{"id":1}`},
+		{ID: 1, Actor: "pedro", Action: "link.create", Alias: "a", After: `{"alias":"a"}`},
+	}
+
+	cases := []struct {
+		name      string
+		query     string
+		filter    storage.AuditFilter
+		entries   []storage.AuditEntry
+		respError string
+		mockError error
+		status    int
+		skipList  bool
+		ids       []int64
+		hasNext   bool
+	}{
+		{
+			name:    "Defaults",
+			filter:  storage.AuditFilter{Limit: 51},
+			entries: entries,
+			status:  http.StatusOK,
+			ids:     []int64{3, 2, 1},
+		},
+		{
+			name:  "Filters and next page",
+			query: "?limit=2&actor=pedro&action=link.create&alias=a&since=2024-05-01T00:00:00Z&until=2024-06-01T00:00:00Z",
+			filter: storage.AuditFilter{
+				Actor:  "pedro",
+				Action: "link.create",
+				Alias:  "a",
+				Since:  &since,
+				Until:  &until,
+				Limit:  3,
+			},
+			entries: entries,
+			status:  http.StatusOK,
+			ids:     []int64{3, 2},
+			hasNext: true,
+		},
+		{
+			name:      "Invalid limit",
+			query:     "?limit=1000",
+			respError: "field Limit must be at most 200",
+			status:    http.StatusBadRequest,
+			skipList:  true,
+		},
+		{
+			name:      "Invalid time",
+			query:     "?since=yesterday",
+			respError: "failed to decode request",
+			status:    http.StatusBadRequest,
+			skipList:  true,
+		},
+		{
+			name:      "Invalid cursor",
+			query:     "?cursor=not-a-cursor",
+			respError: "invalid cursor",
+			status:    http.StatusBadRequest,
+			skipList:  true,
+		},
+		{
+			name:      "Internal error",
+			filter:    storage.AuditFilter{Limit: 51},
+			respError: "failed to list audit log",
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			auditListerMock := mocks.NewAuditLister(t)
+
+			if !tc.skipList {
+				auditListerMock.On("ListAudit", tc.filter).Return(tc.entries, tc.mockError).Once()
+			}
+
+			router := gin.New()
+			router.GET("/api/audit", audit.New(slogdiscard.NewDiscardLogger(), auditListerMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/api/audit"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			if tc.respError != "" {
+				require.Contains(t, rr.Body.String(), tc.respError)
+				return
+			}
+
+			var res audit.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+			var ids []int64
+			for _, entry := range res.Entries {
+				ids = append(ids, entry.ID)
+			}
+			require.Equal(t, tc.ids, ids)
+			require.Equal(t, tc.hasNext, res.NextCursor != "")
+		})
+	}
+}
+
+func TestAuditHandler_Values(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	auditListerMock := mocks.NewAuditLister(t)
+	auditListerMock.On("ListAudit", mock.Anything).Return([]storage.AuditEntry{
+		{ID: 1, Actor: "pedro", Action: "link.update", Alias: "a", Before: `{"url":"https://a.com"}`, After: `{"url":"https://b.com"}`, RequestID: "req-1", ClientIP: "10.0.0.1"},
+		{ID: 2, Actor: "pedro", Action: "link.create", Alias: "b", After: `{"url":"https://b.com"}`},
+		{ID: 3, Actor: "pedro", Action: "key.revoke", TargetID: 4, After: `{"revoked_at":"2024-05-01T00:00:00Z"}`},
+	}, nil).Once()
+
+	router := gin.New()
+	router.GET("/api/audit", audit.New(slogdiscard.NewDiscardLogger(), auditListerMock))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/audit", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Before and after are embedded as JSON objects, not strings.
+	body := rr.Body.String()
+	require.Contains(t, body, `"before":{"url":"https://a.com"},"after":{"url":"https://b.com"}`)
+	require.Contains(t, body, `"request_id":"req-1","client_ip":"10.0.0.1"`)
+	require.Contains(t, body, `"action":"key.revoke","target_id":4,`)
+	require.NotContains(t, body, `"before":null`)
+}
+
+func TestAuditHandler_CursorRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	auditListerMock := mocks.NewAuditLister(t)
+
+	auditListerMock.On("ListAudit", mock.MatchedBy(func(f storage.AuditFilter) bool {
+		return f.BeforeID == 0
+	})).Return([]storage.AuditEntry{{ID: 8}, {ID: 7}}, nil).Once()
+
+	auditListerMock.On("ListAudit", mock.MatchedBy(func(f storage.AuditFilter) bool {
+		return f.BeforeID == 8
+	})).Return([]storage.AuditEntry{{ID: 7}}, nil).Once()
+
+	router := gin.New()
+	router.GET("/api/audit", audit.New(slogdiscard.NewDiscardLogger(), auditListerMock))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/audit?limit=1", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var res audit.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	require.NotEmpty(t, res.NextCursor)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/audit?limit=1&cursor="+res.NextCursor, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	res = audit.Response{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	require.Len(t, res.Entries, 1)
+	require.Empty(t, res.NextCursor)
+}
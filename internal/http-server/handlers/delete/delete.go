@@ -5,6 +5,8 @@ import (
 	"log/slog"
 	"net/http"
 
+	"url-shortener/internal/audit"
+	"url-shortener/internal/http-server/middleware/auth"
 	resp "url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/sl"
 	"url-shortener/internal/storage"
@@ -14,7 +16,8 @@ import (
 
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=AliasRemover
 type AliasRemover interface {
-	DeleteAlias(alias string) error
+	GetLink(alias string) (storage.Link, error)
+	DeleteAlias(alias string, ownerID int64, audit ...storage.AuditEntry) error
 }
 
 func Delete(log *slog.Logger, aliasRemover AliasRemover) gin.HandlerFunc {
@@ -24,7 +27,7 @@ func Delete(log *slog.Logger, aliasRemover AliasRemover) gin.HandlerFunc {
 		requestID := c.GetString("request_id")
 		alias := c.Param("alias")
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", requestID),
 			slog.String("alias", alias),
@@ -36,12 +39,37 @@ func Delete(log *slog.Logger, aliasRemover AliasRemover) gin.HandlerFunc {
 			return
 		}
 
-		err := aliasRemover.DeleteAlias(alias)
+		// The link is read first so the audit log keeps what was deleted.
+		before, err := aliasRemover.GetLink(alias)
 		if errors.Is(err, storage.ErrURLNotFound) {
 			log.Info("alias not found")
 			c.JSON(http.StatusNotFound, resp.Error("alias not found"))
 			return
 		}
+		if err != nil {
+			log.Error("failed to get link", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to delete alias"))
+			return
+		}
+
+		entry, err := audit.Entry(c, storage.ActionLinkDelete, alias, before.AuditState(), nil)
+		if err != nil {
+			log.Error("failed to build audit entry", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to delete alias"))
+			return
+		}
+
+		err = aliasRemover.DeleteAlias(alias, auth.FromContext(c).OwnerScope(), entry)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("alias not found")
+			c.JSON(http.StatusNotFound, resp.Error("alias not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrNotOwner) {
+			log.Info("alias owned by another user")
+			c.JSON(http.StatusForbidden, resp.Error("alias owned by another user"))
+			return
+		}
 		if errors.Is(err, storage.ErrDBConnection) {
 			log.Error("database connection error", sl.Err(err))
 			c.JSON(http.StatusBadRequest, resp.Error("database connection error"))
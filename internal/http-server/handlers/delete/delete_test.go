@@ -1,6 +1,7 @@
 package delete_test
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -8,7 +9,9 @@ import (
 
 	"url-shortener/internal/http-server/handlers/delete"
 	"url-shortener/internal/http-server/handlers/delete/mocks"
+	"url-shortener/internal/http-server/middleware/auth"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/rbac"
 	"url-shortener/internal/storage"
 
 	"github.com/gin-gonic/gin"
@@ -22,8 +25,10 @@ func TestDeleteHandler(t *testing.T) {
 		name      string
 		alias     string
 		respError string
+		getError  error
 		mockError error
 		status    int
+		role      rbac.Role
 	}{
 		{
 			name:      "Success",
@@ -36,9 +41,29 @@ func TestDeleteHandler(t *testing.T) {
 			name:      "Alias not found",
 			alias:     "non_existent_alias",
 			respError: "alias not found",
+			getError:  storage.ErrURLNotFound,
+			status:    http.StatusNotFound,
+		},
+		{
+			name:      "Deleted concurrently",
+			alias:     "test_alias",
+			respError: "alias not found",
 			mockError: storage.ErrURLNotFound,
 			status:    http.StatusNotFound,
 		},
+		{
+			name:      "Owned by another user",
+			alias:     "test_alias",
+			respError: "alias owned by another user",
+			mockError: storage.ErrNotOwner,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:   "Admin deletes any alias",
+			alias:  "test_alias",
+			status: http.StatusOK,
+			role:   rbac.RoleAdmin,
+		},
 		{
 			name:      "Internal error",
 			alias:     "some_alias",
@@ -46,6 +71,13 @@ func TestDeleteHandler(t *testing.T) {
 			mockError: errors.New("internal error"),
 			status:    http.StatusInternalServerError,
 		},
+		{
+			name:      "Lookup error",
+			alias:     "some_alias",
+			respError: "failed to delete alias",
+			getError:  errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+		},
 		{
 			name:      "Empty alias",
 			alias:     "",
@@ -62,13 +94,37 @@ func TestDeleteHandler(t *testing.T) {
 			t.Parallel()
 			mockAliasRemover := mocks.NewAliasRemover(t)
 
-			if tc.alias != "" && tc.mockError != nil {
-				mockAliasRemover.On("DeleteAlias", tc.alias).Return(tc.mockError).Once()
-			} else if tc.alias != "" {
-				mockAliasRemover.On("DeleteAlias", tc.alias).Return(nil).Once()
+			// Admins are not restricted to their own links.
+			ownerID := int64(5)
+			if tc.role == rbac.RoleAdmin {
+				ownerID = 0
+			}
+
+			link := storage.Link{Alias: tc.alias, URL: "https://example.com", Owner: "pedro", OwnerID: 5}
+
+			if tc.alias != "" {
+				mockAliasRemover.On("GetLink", tc.alias).Return(link, tc.getError).Once()
+			}
+			if tc.alias != "" && tc.getError == nil {
+				before, err := json.Marshal(link.AuditState())
+				require.NoError(t, err)
+
+				// The entry is stored in the transaction of the deletion.
+				entry := storage.AuditEntry{
+					Actor:   "pedro",
+					ActorID: 5,
+					Action:  storage.ActionLinkDelete,
+					Alias:   tc.alias,
+					Before:  string(before),
+				}
+
+				mockAliasRemover.On("DeleteAlias", tc.alias, ownerID, entry).Return(tc.mockError).Once()
 			}
 
 			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				auth.SetPrincipal(c, auth.Principal{UserID: 5, Name: "pedro", Role: tc.role})
+			})
 			router.DELETE("/api/link/:alias", delete.Delete(slogdiscard.NewDiscardLogger(), mockAliasRemover))
 
 			req, err := http.NewRequest(http.MethodDelete, "/api/link/"+tc.alias, nil)
@@ -2,20 +2,32 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
 
 // AliasRemover is an autogenerated mock type for the AliasRemover type
 type AliasRemover struct {
 	mock.Mock
 }
 
-// DeleteAlias provides a mock function with given fields: alias
-func (_m *AliasRemover) DeleteAlias(alias string) error {
-	ret := _m.Called(alias)
+// DeleteAlias provides a mock function with given fields: alias, ownerID, audit
+func (_m *AliasRemover) DeleteAlias(alias string, ownerID int64, audit ...storage.AuditEntry) error {
+	_va := make([]interface{}, len(audit))
+	for _i := range audit {
+		_va[_i] = audit[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, alias)
+	_ca = append(_ca, ownerID)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(alias)
+	if rf, ok := ret.Get(0).(func(string, int64, ...storage.AuditEntry) error); ok {
+		r0 = rf(alias, ownerID, audit...)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -23,6 +35,30 @@ func (_m *AliasRemover) DeleteAlias(alias string) error {
 	return r0
 }
 
+// GetLink provides a mock function with given fields: alias
+func (_m *AliasRemover) GetLink(alias string) (storage.Link, error) {
+	ret := _m.Called(alias)
+
+	var r0 storage.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (storage.Link, error)); ok {
+		return rf(alias)
+	}
+	if rf, ok := ret.Get(0).(func(string) storage.Link); ok {
+		r0 = rf(alias)
+	} else {
+		r0 = ret.Get(0).(storage.Link)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(alias)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewAliasRemover interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,82 @@
+package info
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"url-shortener/internal/http-server/middleware/auth"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Response struct {
+	resp.Response
+	Alias          string     `json:"alias"`
+	URL            string     `json:"url"`
+	CanonicalURL   string     `json:"canonical_url"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	Expired        bool       `json:"expired"`
+	Protected      bool       `json:"protected"`
+	RedirectStatus int        `json:"redirect_status"`
+	ForwardQuery   bool       `json:"forward_query"`
+	ForwardPath    bool       `json:"forward_path"`
+	Owner          string     `json:"owner,omitempty"`
+	Clicks         int64      `json:"clicks"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=LinkGetter
+type LinkGetter interface {
+	GetLink(alias string) (storage.Link, error)
+}
+
+func New(log *slog.Logger, linkGetter LinkGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const op = "handlers.url.info.New"
+
+		alias := c.Param("alias")
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+			slog.String("alias", alias),
+		)
+
+		link, err := linkGetter.GetLink(alias)
+		if err == nil && !link.OwnedBy(auth.FromContext(c).OwnerScope()) {
+			// Links of other users are not revealed to exist.
+			err = storage.ErrURLNotFound
+		}
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("alias not found")
+			c.JSON(http.StatusNotFound, resp.Error("alias not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get link", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to get link"))
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Response:       resp.OK(),
+			Alias:          link.Alias,
+			URL:            link.URL,
+			CanonicalURL:   link.Canonical(),
+			CreatedAt:      link.CreatedAt,
+			ExpiresAt:      link.ExpiresAt,
+			Expired:        link.Expired(time.Now()),
+			Protected:      link.PasswordHash != "",
+			RedirectStatus: link.StatusCode(),
+			ForwardQuery:   link.ForwardQuery,
+			ForwardPath:    link.ForwardPath,
+			Owner:          link.Owner,
+			Clicks:         link.Clicks,
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package info_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/info"
+	"url-shortener/internal/http-server/handlers/info/mocks"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfoHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	expiredAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		alias     string
+		link      storage.Link
+		respError string
+		respBody  string
+		mockError error
+		status    int
+		principal auth.Principal
+	}{
+		{
+			name:  "Success",
+			alias: "test_alias",
+			link: storage.Link{
+				Alias:          "test_alias",
+				URL:            "https://Example.com",
+				CanonicalURL:   "https://example.com/",
+				Owner:          "pedro",
+				CreatedAt:      createdAt,
+				Clicks:         42,
+				PasswordHash:   "hash",
+				RedirectStatus: http.StatusMovedPermanently,
+				ForwardQuery:   true,
+			},
+			respBody: `{"status":"OK","alias":"test_alias","url":"https://Example.com","canonical_url":"https://example.com/","created_at":"2024-05-01T12:00:00Z","expired":false,"protected":true,"redirect_status":301,"forward_query":true,"forward_path":false,"owner":"pedro","clicks":42}`,
+			status:   http.StatusOK,
+		},
+		{
+			name:  "Expired",
+			alias: "expired_alias",
+			link: storage.Link{
+				Alias:     "expired_alias",
+				URL:       "https://example.com",
+				CreatedAt: createdAt,
+				ExpiresAt: &expiredAt,
+			},
+			respBody: `{"status":"OK","alias":"expired_alias","url":"https://example.com","canonical_url":"https://example.com","created_at":"2024-05-01T12:00:00Z","expires_at":"2024-06-01T12:00:00Z","expired":true,"protected":false,"redirect_status":302,"forward_query":false,"forward_path":false,"clicks":0}`,
+			status:   http.StatusOK,
+		},
+		{
+			name:      "Alias not found",
+			alias:     "unknown",
+			respError: "alias not found",
+			mockError: storage.ErrURLNotFound,
+			status:    http.StatusNotFound,
+		},
+		{
+			name:      "Link of another user",
+			alias:     "test_alias",
+			link:      storage.Link{Alias: "test_alias", URL: "https://example.com", OwnerID: 5},
+			respError: "alias not found",
+			status:    http.StatusNotFound,
+			principal: auth.Principal{UserID: 9, Name: "viewer", Role: rbac.RoleViewer},
+		},
+		{
+			name:      "Admin reads every link",
+			alias:     "test_alias",
+			link:      storage.Link{Alias: "test_alias", URL: "https://example.com", OwnerID: 5, CreatedAt: createdAt},
+			respBody:  `{"status":"OK","alias":"test_alias","url":"https://example.com","canonical_url":"https://example.com","created_at":"2024-05-01T12:00:00Z","expired":false,"protected":false,"redirect_status":302,"forward_query":false,"forward_path":false,"clicks":0}`,
+			status:    http.StatusOK,
+			principal: auth.Principal{UserID: 1, Name: "admin", Role: rbac.RoleAdmin},
+		},
+		{
+			name:      "Internal error",
+			alias:     "test_alias",
+			respError: "failed to get link",
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			linkGetterMock := mocks.NewLinkGetter(t)
+
+			linkGetterMock.On("GetLink", tc.alias).Return(tc.link, tc.mockError).Once()
+
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				auth.SetPrincipal(c, tc.principal)
+			})
+			router.GET("/api/link/:alias", info.New(slogdiscard.NewDiscardLogger(), linkGetterMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/api/link/"+tc.alias, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			if tc.respError != "" {
+				require.Contains(t, rr.Body.String(), tc.respError)
+			} else {
+				require.JSONEq(t, tc.respBody, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package keys
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"url-shortener/internal/audit"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/expiry"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type CreateRequest struct {
+	// Name describes what the key is used for.
+	Name string `json:"name" validate:"required,max=64"`
+	// User is the name of the user the key authenticates as.
+	User string `json:"user" validate:"required"`
+	// Role restricts the key to a less privileged role than the one of the
+	// user, the key acts with the role of the user when omitted.
+	Role      string     `json:"role,omitempty" validate:"omitempty,oneof=viewer editor admin"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" validate:"omitempty,excluded_with=TTL"`
+	TTL       string     `json:"ttl,omitempty" validate:"omitempty,excluded_with=ExpiresAt"`
+}
+
+type CreateResponse struct {
+	resp.Response
+	ID        int64      `json:"id,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	User      string     `json:"user,omitempty"`
+	Role      string     `json:"role,omitempty"`
+	Prefix    string     `json:"prefix,omitempty"`
+	Key       string     `json:"key,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=KeyCreator
+type KeyCreator interface {
+	GetUserByName(name string) (storage.User, error)
+	CreateAPIKey(key storage.APIKey, audit ...storage.AuditEntry) (int64, error)
+}
+
+func NewCreate(log *slog.Logger, keyCreator KeyCreator) gin.HandlerFunc {
+	validate := validator.New()
+
+	return func(c *gin.Context) {
+		const op = "handlers.keys.NewCreate"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+		)
+
+		var req CreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error("failed to decode request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.ValidationError(validateErr))
+			return
+		}
+
+		expiresAt, err := expiry.Resolve(req.ExpiresAt, req.TTL, time.Now())
+		if err != nil {
+			log.Error("invalid expiry", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		user, err := keyCreator.GetUserByName(req.User)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", slog.String("user", req.User))
+			c.JSON(http.StatusNotFound, resp.Error("user not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to look up user", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to create api key"))
+			return
+		}
+
+		token, err := apikey.Generate()
+		if err != nil {
+			log.Error("failed to generate api key", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to create api key"))
+			return
+		}
+
+		key := storage.APIKey{
+			Name:      req.Name,
+			UserID:    user.ID,
+			Role:      req.Role,
+			Prefix:    apikey.Prefix(token),
+			Hash:      apikey.Hash(token),
+			ExpiresAt: expiresAt,
+		}
+
+		// The storage records the id of the new key as the target of the entry.
+		entry, err := audit.Entry(c, storage.ActionKeyCreate, "", nil, fromStorage(storage.APIKey{
+			Name:      key.Name,
+			User:      user.Name,
+			Role:      key.Role,
+			Prefix:    key.Prefix,
+			CreatedAt: time.Now(),
+			ExpiresAt: key.ExpiresAt,
+		}))
+		if err != nil {
+			log.Error("failed to build audit entry", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to create api key"))
+			return
+		}
+
+		id, err := keyCreator.CreateAPIKey(key, entry)
+		if err != nil {
+			log.Error("failed to create api key", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to create api key"))
+			return
+		}
+
+		log.Info("api key created", slog.Int64("id", id), slog.String("name", key.Name), slog.String("user", user.Name))
+
+		c.JSON(http.StatusCreated, CreateResponse{
+			Response:  resp.OK(),
+			ID:        id,
+			Name:      key.Name,
+			User:      user.Name,
+			Role:      key.Role,
+			Prefix:    key.Prefix,
+			Key:       token,
+			ExpiresAt: expiresAt,
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package keys_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/keys"
+	"url-shortener/internal/http-server/handlers/keys/mocks"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	past := time.Now().Add(-time.Hour)
+
+	cases := []struct {
+		name      string
+		request   keys.CreateRequest
+		mockError error
+		userError error
+		skipUser  bool
+		skipSave  bool
+		expires   bool
+		status    int
+		respError string
+	}{
+		{
+			name:    "Success",
+			request: keys.CreateRequest{Name: "ci", User: "billing"},
+			status:  http.StatusCreated,
+		},
+		{
+			name:    "With TTL",
+			request: keys.CreateRequest{Name: "ci", User: "billing", TTL: "720h"},
+			expires: true,
+			status:  http.StatusCreated,
+		},
+		{
+			name:    "Restricted role",
+			request: keys.CreateRequest{Name: "dashboard", User: "billing", Role: "viewer"},
+			status:  http.StatusCreated,
+		},
+		{
+			name:      "Unknown role",
+			request:   keys.CreateRequest{Name: "ci", User: "billing", Role: "root"},
+			skipUser:  true,
+			skipSave:  true,
+			status:    http.StatusBadRequest,
+			respError: "field Role must be one of: viewer, editor, admin",
+		},
+		{
+			name:      "Missing name",
+			request:   keys.CreateRequest{User: "billing"},
+			skipUser:  true,
+			skipSave:  true,
+			status:    http.StatusBadRequest,
+			respError: "field Name is a required field",
+		},
+		{
+			name:      "Expiry in the past",
+			request:   keys.CreateRequest{Name: "ci", User: "billing", ExpiresAt: &past},
+			skipSave:  true,
+			status:    http.StatusBadRequest,
+			respError: "field ExpiresAt must be in the future",
+			skipUser:  true,
+		},
+		{
+			name:      "Missing user",
+			request:   keys.CreateRequest{Name: "ci"},
+			skipUser:  true,
+			skipSave:  true,
+			status:    http.StatusBadRequest,
+			respError: "field User is a required field",
+		},
+		{
+			name:      "Unknown user",
+			request:   keys.CreateRequest{Name: "ci", User: "nobody"},
+			userError: storage.ErrUserNotFound,
+			skipSave:  true,
+			status:    http.StatusNotFound,
+			respError: "user not found",
+		},
+		{
+			name:      "Internal error",
+			request:   keys.CreateRequest{Name: "ci", User: "billing"},
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+			respError: "failed to create api key",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stored storage.APIKey
+
+			keyCreatorMock := mocks.NewKeyCreator(t)
+			if !tc.skipUser {
+				keyCreatorMock.On("GetUserByName", tc.request.User).
+					Return(storage.User{ID: 3, Name: tc.request.User}, tc.userError).Once()
+			}
+			if !tc.skipSave {
+				keyCreatorMock.On("CreateAPIKey", mock.MatchedBy(func(key storage.APIKey) bool {
+					return key.Name == tc.request.Name && key.UserID == 3 && key.Role == tc.request.Role &&
+						(key.ExpiresAt != nil) == tc.expires
+				}), mock.MatchedBy(func(entry storage.AuditEntry) bool {
+					// The audit log gets the key without its token or hash.
+					var key keys.Key
+					return entry.Action == storage.ActionKeyCreate &&
+						json.Unmarshal([]byte(entry.After), &key) == nil &&
+						key.Name == tc.request.Name && key.User == "billing" && key.Prefix != ""
+				})).Run(func(args mock.Arguments) {
+					stored = args.Get(0).(storage.APIKey)
+				}).Return(int64(7), tc.mockError).Once()
+			}
+
+			router := gin.New()
+			router.POST("/keys", keys.NewCreate(slogdiscard.NewDiscardLogger(), keyCreatorMock))
+
+			body, err := json.Marshal(tc.request)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/keys", bytes.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			var res keys.CreateResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			require.Equal(t, tc.respError, res.Error)
+
+			if tc.status == http.StatusCreated {
+				require.Equal(t, int64(7), res.ID)
+				require.Equal(t, "billing", res.User)
+				require.Equal(t, tc.request.Role, res.Role)
+				require.True(t, apikey.Valid(res.Key))
+				require.Equal(t, apikey.Prefix(res.Key), res.Prefix)
+
+				// Only the hash of the token is stored.
+				require.Equal(t, apikey.Hash(res.Key), stored.Hash)
+				require.NotContains(t, stored.Hash, res.Key)
+			}
+		})
+	}
+}
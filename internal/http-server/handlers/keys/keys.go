@@ -0,0 +1,35 @@
+package keys
+
+import (
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+// Key is an API key as shown to admins. The token itself is only returned
+// once, by the create handler.
+type Key struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	User       string     `json:"user"`
+	Role       string     `json:"role,omitempty"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func fromStorage(key storage.APIKey) Key {
+	return Key{
+		ID:         key.ID,
+		Name:       key.Name,
+		User:       key.User,
+		Role:       key.Role,
+		Prefix:     key.Prefix,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
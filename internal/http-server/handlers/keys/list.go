@@ -0,0 +1,50 @@
+package keys
+
+import (
+	"log/slog"
+	"net/http"
+
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ListResponse struct {
+	resp.Response
+	Keys []Key `json:"keys"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=KeyLister
+type KeyLister interface {
+	ListAPIKeys() ([]storage.APIKey, error)
+}
+
+func NewList(log *slog.Logger, keyLister KeyLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const op = "handlers.keys.NewList"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+		)
+
+		stored, err := keyLister.ListAPIKeys()
+		if err != nil {
+			log.Error("failed to list api keys", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to list api keys"))
+			return
+		}
+
+		keys := make([]Key, 0, len(stored))
+		for _, key := range stored {
+			keys = append(keys, fromStorage(key))
+		}
+
+		c.JSON(http.StatusOK, ListResponse{
+			Response: resp.OK(),
+			Keys:     keys,
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package keys_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/keys"
+	"url-shortener/internal/http-server/handlers/keys/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	used := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		keys      []storage.APIKey
+		mockError error
+		status    int
+		want      []keys.Key
+		respError string
+	}{
+		{
+			name: "Success",
+			keys: []storage.APIKey{
+				{ID: 1, Name: "ci", UserID: 3, User: "billing", Prefix: "usk_abcdefgh", Hash: "secret-hash", LastUsedAt: &used},
+				{ID: 2, Name: "bot", Prefix: "usk_12345678", Hash: "other-hash", RevokedAt: &used},
+			},
+			status: http.StatusOK,
+			want: []keys.Key{
+				{ID: 1, Name: "ci", User: "billing", Prefix: "usk_abcdefgh", LastUsedAt: &used},
+				{ID: 2, Name: "bot", Prefix: "usk_12345678", RevokedAt: &used},
+			},
+		},
+		{
+			name:   "No keys",
+			keys:   []storage.APIKey{},
+			status: http.StatusOK,
+			want:   []keys.Key{},
+		},
+		{
+			name:      "Internal error",
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+			respError: "failed to list api keys",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keyListerMock := mocks.NewKeyLister(t)
+			keyListerMock.On("ListAPIKeys").Return(tc.keys, tc.mockError).Once()
+
+			router := gin.New()
+			router.GET("/keys", keys.NewList(slogdiscard.NewDiscardLogger(), keyListerMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/keys", nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+			require.NotContains(t, rr.Body.String(), "hash")
+
+			var res keys.ListResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			require.Equal(t, tc.respError, res.Error)
+			require.Equal(t, tc.want, res.Keys)
+		})
+	}
+}
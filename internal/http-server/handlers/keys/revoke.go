@@ -0,0 +1,67 @@
+package keys
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortener/internal/audit"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=KeyRevoker
+type KeyRevoker interface {
+	RevokeAPIKey(id int64, at time.Time, audit ...storage.AuditEntry) error
+}
+
+// NewRevoke revokes the key with the :id path parameter. Revoked keys stay
+// listed so their last use remains visible.
+func NewRevoke(log *slog.Logger, keyRevoker KeyRevoker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const op = "handlers.keys.NewRevoke"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+		)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			log.Info("invalid api key id", slog.String("id", c.Param("id")))
+			c.JSON(http.StatusBadRequest, resp.Error("invalid api key id"))
+			return
+		}
+
+		revokedAt := time.Now()
+
+		entry, err := audit.Entry(c, storage.ActionKeyRevoke, "", nil, gin.H{"revoked_at": revokedAt})
+		if err != nil {
+			log.Error("failed to build audit entry", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to revoke api key"))
+			return
+		}
+		entry.TargetID = id
+
+		err = keyRevoker.RevokeAPIKey(id, revokedAt, entry)
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Info("api key not found", slog.Int64("id", id))
+			c.JSON(http.StatusNotFound, resp.Error("api key not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to revoke api key", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to revoke api key"))
+			return
+		}
+
+		log.Info("api key revoked", slog.Int64("id", id))
+
+		c.JSON(http.StatusOK, resp.OK())
+	}
+}
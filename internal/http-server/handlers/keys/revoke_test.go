@@ -0,0 +1,86 @@
+package keys_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/http-server/handlers/keys"
+	"url-shortener/internal/http-server/handlers/keys/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name      string
+		id        string
+		mockError error
+		skipMock  bool
+		status    int
+		respError string
+	}{
+		{
+			name:   "Success",
+			id:     "3",
+			status: http.StatusOK,
+		},
+		{
+			name:      "Key not found",
+			id:        "3",
+			mockError: storage.ErrAPIKeyNotFound,
+			status:    http.StatusNotFound,
+			respError: "api key not found",
+		},
+		{
+			name:      "Invalid id",
+			id:        "abc",
+			skipMock:  true,
+			status:    http.StatusBadRequest,
+			respError: "invalid api key id",
+		},
+		{
+			name:      "Internal error",
+			id:        "3",
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+			respError: "failed to revoke api key",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keyRevokerMock := mocks.NewKeyRevoker(t)
+			if !tc.skipMock {
+				keyRevokerMock.On("RevokeAPIKey", int64(3), mock.AnythingOfType("time.Time"), mock.MatchedBy(func(entry storage.AuditEntry) bool {
+					return entry.Action == storage.ActionKeyRevoke && entry.TargetID == 3
+				})).Return(tc.mockError).Once()
+			}
+
+			router := gin.New()
+			router.DELETE("/keys/:id", keys.NewRevoke(slogdiscard.NewDiscardLogger(), keyRevokerMock))
+
+			req, err := http.NewRequest(http.MethodDelete, "/keys/"+tc.id, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+			if tc.respError != "" {
+				require.Contains(t, rr.Body.String(), tc.respError)
+			}
+		})
+	}
+}
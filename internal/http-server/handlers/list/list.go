@@ -0,0 +1,170 @@
+package list
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/internal/http-server/middleware/auth"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const defaultLimit = 50
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+type Request struct {
+	Cursor      string `form:"cursor"`
+	Limit       int    `form:"limit" validate:"omitempty,min=1,max=200"`
+	Order       string `form:"order" validate:"omitempty,oneof=asc desc"`
+	AliasPrefix string `form:"alias_prefix"`
+	URL         string `form:"url"`
+	Host        string `form:"host"`
+}
+
+type Link struct {
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url"`
+	Owner     string     `json:"owner,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Links      []Link `json:"links"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=LinkLister
+type LinkLister interface {
+	ListLinks(params storage.ListParams) ([]storage.Link, error)
+}
+
+func New(log *slog.Logger, linkLister LinkLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const op = "handlers.url.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+		)
+
+		var req Request
+		if err := c.ShouldBindQuery(&req); err != nil {
+			log.Error("failed to decode query", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error("failed to decode request"))
+			return
+		}
+
+		validate := validator.New()
+		if err := validate.Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.ValidationError(validateErr))
+			return
+		}
+
+		params := storage.ListParams{
+			OwnerID:     auth.FromContext(c).OwnerScope(),
+			AliasPrefix: req.AliasPrefix,
+			URLContains: req.URL,
+			Host:        req.Host,
+			Order:       storage.SortDesc,
+			Limit:       defaultLimit,
+		}
+
+		if req.Order != "" {
+			params.Order = storage.SortOrder(req.Order)
+		}
+		if req.Limit != 0 {
+			params.Limit = req.Limit
+		}
+
+		if req.Cursor != "" {
+			cursor, err := decodeCursor(req.Cursor)
+			if err != nil {
+				log.Info("invalid cursor", sl.Err(err))
+				c.JSON(http.StatusBadRequest, resp.Error(ErrInvalidCursor.Error()))
+				return
+			}
+
+			params.After = &cursor
+		}
+
+		// One extra link tells whether there is a next page.
+		limit := params.Limit
+		params.Limit++
+
+		links, err := linkLister.ListLinks(params)
+		if err != nil {
+			log.Error("failed to list links", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to list links"))
+			return
+		}
+
+		res := Response{
+			Response: resp.OK(),
+			Links:    make([]Link, 0, len(links)),
+		}
+
+		if len(links) > limit {
+			links = links[:limit]
+			last := links[len(links)-1]
+			res.NextCursor = encodeCursor(storage.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		}
+
+		for _, link := range links {
+			res.Links = append(res.Links, Link{
+				Alias:     link.Alias,
+				URL:       link.URL,
+				Owner:     link.Owner,
+				CreatedAt: link.CreatedAt,
+				ExpiresAt: link.ExpiresAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func encodeCursor(cursor storage.Cursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(cursor.ID, 10)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(encoded string) (storage.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return storage.Cursor{}, err
+	}
+
+	rawTime, rawID, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return storage.Cursor{}, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, rawTime)
+	if err != nil {
+		return storage.Cursor{}, err
+	}
+
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return storage.Cursor{}, err
+	}
+
+	return storage.Cursor{CreatedAt: createdAt, ID: id}, nil
+}
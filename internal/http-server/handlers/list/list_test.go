@@ -0,0 +1,201 @@
+package list_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/list"
+	"url-shortener/internal/http-server/handlers/list/mocks"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	links := []storage.Link{
+		{ID: 3, Alias: "c", URL: "https://c.com", CreatedAt: createdAt.Add(2 * time.Second)},
+		{ID: 2, Alias: "b", URL: "https://b.com", CreatedAt: createdAt.Add(time.Second)},
+		{ID: 1, Alias: "a", URL: "https://a.com", CreatedAt: createdAt},
+	}
+
+	cases := []struct {
+		name      string
+		query     string
+		params    storage.ListParams
+		links     []storage.Link
+		respError string
+		mockError error
+		status    int
+		skipList  bool
+		aliases   []string
+		hasNext   bool
+		principal auth.Principal
+	}{
+		{
+			name:    "Defaults",
+			params:  storage.ListParams{Order: storage.SortDesc, Limit: 51},
+			links:   links,
+			status:  http.StatusOK,
+			aliases: []string{"c", "b", "a"},
+		},
+		{
+			name:      "Scoped to the caller",
+			params:    storage.ListParams{OwnerID: 5, Order: storage.SortDesc, Limit: 51},
+			links:     links,
+			status:    http.StatusOK,
+			aliases:   []string{"c", "b", "a"},
+			principal: auth.Principal{UserID: 5, Name: "pedro"},
+		},
+		{
+			name:      "Viewer scoped to its own links",
+			params:    storage.ListParams{OwnerID: 9, Order: storage.SortDesc, Limit: 51},
+			links:     links,
+			status:    http.StatusOK,
+			aliases:   []string{"c", "b", "a"},
+			principal: auth.Principal{UserID: 9, Name: "viewer", Role: rbac.RoleViewer},
+		},
+		{
+			name:      "Admin lists every link",
+			params:    storage.ListParams{Order: storage.SortDesc, Limit: 51},
+			links:     links,
+			status:    http.StatusOK,
+			aliases:   []string{"c", "b", "a"},
+			principal: auth.Principal{UserID: 1, Name: "admin", Role: rbac.RoleAdmin},
+		},
+		{
+			name:  "Filters and next page",
+			query: "?limit=2&order=asc&alias_prefix=go-&url=golang&host=go.dev",
+			params: storage.ListParams{
+				AliasPrefix: "go-",
+				URLContains: "golang",
+				Host:        "go.dev",
+				Order:       storage.SortAsc,
+				Limit:       3,
+			},
+			links:   links,
+			status:  http.StatusOK,
+			aliases: []string{"c", "b"},
+			hasNext: true,
+		},
+		{
+			name:      "Invalid order",
+			query:     "?order=random",
+			respError: "field Order must be one of: asc, desc",
+			status:    http.StatusBadRequest,
+			skipList:  true,
+		},
+		{
+			name:      "Invalid limit",
+			query:     "?limit=1000",
+			respError: "field Limit must be at most 200",
+			status:    http.StatusBadRequest,
+			skipList:  true,
+		},
+		{
+			name:      "Invalid cursor",
+			query:     "?cursor=not-a-cursor",
+			respError: "invalid cursor",
+			status:    http.StatusBadRequest,
+			skipList:  true,
+		},
+		{
+			name:      "Internal error",
+			params:    storage.ListParams{Order: storage.SortDesc, Limit: 51},
+			respError: "failed to list links",
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			linkListerMock := mocks.NewLinkLister(t)
+
+			if !tc.skipList {
+				linkListerMock.On("ListLinks", tc.params).Return(tc.links, tc.mockError).Once()
+			}
+
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				auth.SetPrincipal(c, tc.principal)
+			})
+			router.GET("/api/links", list.New(slogdiscard.NewDiscardLogger(), linkListerMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/api/links"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			if tc.respError != "" {
+				require.Contains(t, rr.Body.String(), tc.respError)
+				return
+			}
+
+			var res list.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+			var aliases []string
+			for _, link := range res.Links {
+				aliases = append(aliases, link.Alias)
+			}
+			require.Equal(t, tc.aliases, aliases)
+			require.Equal(t, tc.hasNext, res.NextCursor != "")
+		})
+	}
+}
+
+func TestListHandler_CursorRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	linkListerMock := mocks.NewLinkLister(t)
+
+	linkListerMock.On("ListLinks", mock.MatchedBy(func(p storage.ListParams) bool {
+		return p.After == nil
+	})).Return([]storage.Link{
+		{ID: 7, Alias: "a", CreatedAt: createdAt},
+		{ID: 8, Alias: "b", CreatedAt: createdAt},
+	}, nil).Once()
+
+	linkListerMock.On("ListLinks", mock.MatchedBy(func(p storage.ListParams) bool {
+		return p.After != nil && p.After.ID == 7 && p.After.CreatedAt.Equal(createdAt)
+	})).Return([]storage.Link{{ID: 8, Alias: "b", CreatedAt: createdAt}}, nil).Once()
+
+	router := gin.New()
+	router.GET("/api/links", list.New(slogdiscard.NewDiscardLogger(), linkListerMock))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/links?limit=1", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var res list.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	require.NotEmpty(t, res.NextCursor)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/links?limit=1&cursor="+res.NextCursor, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	res = list.Response{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	require.Len(t, res.Links, 1)
+	require.Empty(t, res.NextCursor)
+}
@@ -2,25 +2,68 @@ package redirect
 
 import (
 	"errors"
+	"html/template"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/domainpolicy"
 	"url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/passthrough"
+	"url-shortener/internal/lib/throttle"
 	"url-shortener/internal/storage"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"golang.org/x/crypto/bcrypt"
 )
 
-//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=URLGetter
-type URLGetter interface {
-	GetURL(alias string) (string, error)
+// PasswordHeader carries the password of a protected link for API clients,
+// which may also pass it in the password query parameter.
+const PasswordHeader = "X-Link-Password"
+
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Password required</title></head>
+<body>
+<form method="post">
+<p>{{.}}</p>
+<input type="password" name="password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=LinkResolver
+type LinkResolver interface {
+	ResolveLink(alias string) (storage.Link, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=DestinationChecker
+type DestinationChecker interface {
+	Check(host string) error
 }
 
-func New(log *slog.Logger, urlGetter URLGetter) gin.HandlerFunc {
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=ClickRecorder
+type ClickRecorder interface {
+	RecordClick(click storage.Click) error
+}
+
+func New(
+	log *slog.Logger,
+	linkResolver LinkResolver,
+	clickRecorder ClickRecorder,
+	checker DestinationChecker,
+	attempts *throttle.Limiter,
+) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		const op = "handlers.url.redirect.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", c.GetString("request_id")),
 		)
@@ -32,7 +75,12 @@ func New(log *slog.Logger, urlGetter URLGetter) gin.HandlerFunc {
 			return
 		}
 
-		resURL, err := urlGetter.GetURL(alias)
+		link, err := linkResolver.ResolveLink(alias)
+		if errors.Is(err, storage.ErrURLExpired) {
+			log.Info("url expired", slog.String("alias", alias))
+			c.JSON(http.StatusGone, response.Error("link expired"))
+			return
+		}
 		if errors.Is(err, storage.ErrURLNotFound) {
 			log.Info("url not found", slog.String("alias", alias))
 			c.JSON(http.StatusNotFound, response.Error("not found"))
@@ -44,8 +92,144 @@ func New(log *slog.Logger, urlGetter URLGetter) gin.HandlerFunc {
 			return
 		}
 
-		log.Info("got url", slog.String("url", resURL))
+		log.Info("got url", slog.String("url", link.URL))
+
+		extraPath := wildcardPath(c)
+		if extraPath != "" && !link.ForwardPath {
+			log.Info("path passthrough disabled", slog.String("alias", alias))
+			c.JSON(http.StatusNotFound, response.Error("not found"))
+			return
+		}
+
+		// Links saved before a rule was added are re-checked on every visit.
+		var violation *domainpolicy.Violation
+		err = checker.Check(storage.HostOf(link.URL))
+		if errors.As(err, &violation) {
+			log.Info("destination rejected by domain policy", slog.String("alias", alias), slog.String("rule", violation.Rule))
+			c.JSON(http.StatusForbidden, response.Violation(violation.Error(), violation.Rule))
+			return
+		}
+		if err != nil {
+			log.Error("failed to check domain policy", sl.Err(err), slog.String("alias", alias))
+			c.JSON(http.StatusInternalServerError, response.Error("internal error"))
+			return
+		}
+
+		if link.PasswordHash != "" && !unlock(c, log, link, attempts) {
+			return
+		}
+
+		target, err := destination(c, link, extraPath)
+		if err != nil {
+			log.Info("invalid passthrough path", sl.Err(err), slog.String("alias", alias))
+			c.JSON(http.StatusBadRequest, response.Error("invalid path"))
+			return
+		}
+
+		err = clickRecorder.RecordClick(storage.Click{
+			Alias:     alias,
+			Timestamp: time.Now(),
+			Referrer:  c.Request.Referer(),
+			UserAgent: c.Request.UserAgent(),
+			IP:        c.ClientIP(),
+		})
+		if err != nil {
+			log.Error("failed to record click", sl.Err(err), slog.String("alias", alias))
+		}
+
+		status := link.StatusCode()
+		if c.Request.Method == http.MethodPost {
+			// An unlocked password form must not be re-posted to the
+			// destination, as 307 and 308 would do.
+			status = http.StatusSeeOther
+		}
 
-		c.Redirect(http.StatusFound, resURL)
+		c.Redirect(status, target)
 	}
 }
+
+// destination applies the passthrough options of link to the visit.
+func destination(c *gin.Context, link storage.Link, extraPath string) (string, error) {
+	var query string
+	if link.ForwardQuery {
+		query = c.Request.URL.RawQuery
+	}
+
+	if extraPath == "" && query == "" {
+		return link.URL, nil
+	}
+
+	var drop []string
+	if link.PasswordHash != "" {
+		drop = append(drop, "password")
+	}
+
+	return passthrough.Forward(link.URL, extraPath, query, drop...)
+}
+
+// wildcardPath returns the escaped path below the alias as the visitor sent
+// it, or an empty string when there is none. c.Param would return it
+// unescaped, turning an encoded "%2F" into a separator.
+func wildcardPath(c *gin.Context) string {
+	prefix, _, ok := strings.Cut(c.FullPath(), "/*")
+	if !ok {
+		return ""
+	}
+
+	depth := strings.Count(prefix, "/")
+
+	parts := strings.SplitN(c.Request.URL.EscapedPath(), "/", depth+2)
+	if len(parts) < depth+2 || parts[depth+1] == "" {
+		return ""
+	}
+
+	return "/" + parts[depth+1]
+}
+
+// unlock checks the password sent for a protected link and answers the
+// request itself unless the password is correct.
+func unlock(c *gin.Context, log *slog.Logger, link storage.Link, attempts *throttle.Limiter) bool {
+	password := c.GetHeader(PasswordHeader)
+	if password == "" {
+		password = c.Query("password")
+	}
+	if password == "" && c.Request.Method == http.MethodPost {
+		password = c.PostForm("password")
+	}
+
+	if password == "" {
+		log.Info("password required", slog.String("alias", link.Alias))
+		passwordRequired(c, http.StatusUnauthorized, "password required")
+		return false
+	}
+
+	if wait, ok := attempts.Allow(link.Alias); !ok {
+		log.Info("too many password attempts", slog.String("alias", link.Alias))
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		passwordRequired(c, http.StatusTooManyRequests, "too many attempts, try again later")
+		return false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)); err != nil {
+		log.Info("incorrect password", slog.String("alias", link.Alias))
+		passwordRequired(c, http.StatusUnauthorized, "incorrect password")
+		return false
+	}
+
+	attempts.Reset(link.Alias)
+
+	return true
+}
+
+// passwordRequired renders the password form for browsers and a JSON error
+// for everyone else.
+func passwordRequired(c *gin.Context, status int, msg string) {
+	c.Header("Cache-Control", "no-store")
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Render(status, render.HTML{Template: passwordForm, Data: msg})
+		return
+	}
+
+	c.JSON(status, response.Error(msg))
+}
@@ -1,17 +1,25 @@
 package redirect_test
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
+	"url-shortener/internal/domainpolicy"
 	"url-shortener/internal/http-server/handlers/redirect"
 	"url-shortener/internal/http-server/handlers/redirect/mocks"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/throttle"
 	"url-shortener/internal/storage"
 )
 
@@ -19,12 +27,15 @@ func TestSaveHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	cases := []struct {
-		name      string
-		alias     string
-		url       string
-		respError string
-		mockError error
-		status    int
+		name       string
+		alias      string
+		url        string
+		respError  string
+		mockError  error
+		clickError error
+		blocked    bool
+		redirect   int
+		status     int
 	}{
 		{
 			name:      "Success",
@@ -34,6 +45,28 @@ func TestSaveHandler(t *testing.T) {
 			mockError: nil,
 			status:    http.StatusFound,
 		},
+		{
+			name:     "Permanent redirect",
+			alias:    "test_alias",
+			url:      "https://www.google.com/",
+			redirect: http.StatusPermanentRedirect,
+			status:   http.StatusPermanentRedirect,
+		},
+		{
+			name:       "Click not recorded",
+			alias:      "test_alias",
+			url:        "https://www.google.com/",
+			clickError: errors.New("click storage unavailable"),
+			status:     http.StatusFound,
+		},
+		{
+			name:      "Blocked destination",
+			alias:     "blocked_alias",
+			url:       "https://phish.example/login",
+			respError: `"rule":"phish.example"`,
+			blocked:   true,
+			status:    http.StatusForbidden,
+		},
 		{
 			name:      "Not Found",
 			alias:     "unknown_alias",
@@ -41,6 +74,13 @@ func TestSaveHandler(t *testing.T) {
 			mockError: storage.ErrURLNotFound,
 			status:    http.StatusNotFound,
 		},
+		{
+			name:      "Expired",
+			alias:     "expired_alias",
+			respError: "link expired",
+			mockError: storage.ErrURLExpired,
+			status:    http.StatusGone,
+		},
 		{
 			name:      "Internal Error",
 			alias:     "internal_error",
@@ -52,21 +92,46 @@ func TestSaveHandler(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			urlGetterMock := mocks.NewURLGetter(t)
+			linkResolverMock := mocks.NewLinkResolver(t)
+
+			clickRecorderMock := mocks.NewClickRecorder(t)
+
+			checkerMock := mocks.NewDestinationChecker(t)
+
+			linkResolverMock.On("ResolveLink", tc.alias).
+				Return(storage.Link{Alias: tc.alias, URL: tc.url, RedirectStatus: tc.redirect}, tc.mockError).Once()
+
+			if tc.url != "" {
+				var checkErr error
+				if tc.blocked {
+					checkErr = &domainpolicy.Violation{Host: "phish.example", Rule: "phish.example"}
+				}
+				checkerMock.On("Check", storage.HostOf(tc.url)).Return(checkErr).Once()
+			}
+
+			redirected := tc.status >= 300 && tc.status < 400
 
-			urlGetterMock.On("GetURL", tc.alias).
-				Return(tc.url, tc.mockError).Once()
+			if redirected {
+				clickRecorderMock.On("RecordClick", mock.MatchedBy(func(click storage.Click) bool {
+					return click.Alias == tc.alias &&
+						click.Referrer == "https://referrer.com" &&
+						click.UserAgent == "test-agent" &&
+						!click.Timestamp.IsZero()
+				})).Return(tc.clickError).Once()
+			}
 
 			router := gin.Default()
-			router.GET("/:alias", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock))
+			router.GET("/:alias", redirect.New(slogdiscard.NewDiscardLogger(), linkResolverMock, clickRecorderMock, checkerMock, throttle.New(5, time.Minute)))
 
 			req, _ := http.NewRequest(http.MethodGet, "/"+tc.alias, nil)
+			req.Header.Set("Referer", "https://referrer.com")
+			req.Header.Set("User-Agent", "test-agent")
 			rec := httptest.NewRecorder()
 			router.ServeHTTP(rec, req)
 
 			assert.Equal(t, tc.status, rec.Code)
 
-			if tc.status == http.StatusFound {
+			if redirected {
 				assert.Equal(t, tc.url, rec.Header().Get("Location"))
 			} else {
 				require.Contains(t, rec.Body.String(), tc.respError)
@@ -74,3 +139,278 @@ func TestSaveHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRedirectHandler_Password(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	const dest = "https://docs.example.com/private"
+
+	link := storage.Link{Alias: "locked", URL: dest, PasswordHash: string(hash)}
+
+	type request struct {
+		method string
+		query  string
+		header string
+		form   string
+		html   bool
+	}
+
+	cases := []struct {
+		name     string
+		requests []request
+		status   int
+		body     string
+		clicks   int
+	}{
+		{
+			name:     "Password required",
+			requests: []request{{method: http.MethodGet}},
+			status:   http.StatusUnauthorized,
+			body:     `"error":"password required"`,
+		},
+		{
+			name:     "Browser gets a form",
+			requests: []request{{method: http.MethodGet, html: true}},
+			status:   http.StatusUnauthorized,
+			body:     `<input type="password" name="password"`,
+		},
+		{
+			name:     "Header password",
+			requests: []request{{method: http.MethodGet, header: "secret"}},
+			status:   http.StatusFound,
+			clicks:   1,
+		},
+		{
+			name:     "Query password",
+			requests: []request{{method: http.MethodGet, query: "secret"}},
+			status:   http.StatusFound,
+			clicks:   1,
+		},
+		{
+			name:     "Form password",
+			requests: []request{{method: http.MethodPost, form: "secret", html: true}},
+			status:   http.StatusSeeOther,
+			clicks:   1,
+		},
+		{
+			name:     "Incorrect password",
+			requests: []request{{method: http.MethodPost, form: "guess", html: true}},
+			status:   http.StatusUnauthorized,
+			body:     "incorrect password",
+		},
+		{
+			name: "Attempts throttled",
+			requests: []request{
+				{method: http.MethodGet, header: "guess1"},
+				{method: http.MethodGet, header: "guess2"},
+				{method: http.MethodGet, header: "guess3"},
+				{method: http.MethodGet, header: "secret"},
+			},
+			status: http.StatusTooManyRequests,
+			body:   "too many attempts",
+		},
+		{
+			name: "Success resets failures",
+			requests: []request{
+				{method: http.MethodGet, header: "guess1"},
+				{method: http.MethodGet, header: "guess2"},
+				{method: http.MethodGet, header: "secret"},
+				{method: http.MethodGet, header: "guess3"},
+				{method: http.MethodGet, header: "secret"},
+			},
+			status: http.StatusFound,
+			clicks: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			linkResolverMock := mocks.NewLinkResolver(t)
+			linkResolverMock.On("ResolveLink", "locked").Return(link, nil)
+
+			checkerMock := mocks.NewDestinationChecker(t)
+			checkerMock.On("Check", "docs.example.com").Return(nil)
+
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			clickRecorderMock.On("RecordClick", mock.Anything).Return(nil).Maybe()
+
+			handler := redirect.New(slogdiscard.NewDiscardLogger(), linkResolverMock, clickRecorderMock, checkerMock, throttle.New(3, time.Minute))
+
+			router := gin.New()
+			router.GET("/:alias", handler)
+			router.POST("/:alias", handler)
+
+			var rec *httptest.ResponseRecorder
+
+			for _, r := range tc.requests {
+				target := "/locked"
+				if r.query != "" {
+					target += "?password=" + url.QueryEscape(r.query)
+				}
+
+				var body *strings.Reader
+				if r.form != "" {
+					body = strings.NewReader(url.Values{"password": {r.form}}.Encode())
+				} else {
+					body = strings.NewReader("")
+				}
+
+				req, err := http.NewRequest(r.method, target, body)
+				require.NoError(t, err)
+
+				if r.form != "" {
+					req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				}
+				if r.header != "" {
+					req.Header.Set(redirect.PasswordHeader, r.header)
+				}
+				if r.html {
+					req.Header.Set("Accept", "text/html,application/xhtml+xml")
+				}
+
+				rec = httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+			}
+
+			require.Equal(t, tc.status, rec.Code)
+			clickRecorderMock.AssertNumberOfCalls(t, "RecordClick", tc.clicks)
+
+			if tc.clicks > 0 {
+				require.Equal(t, dest, rec.Header().Get("Location"))
+				return
+			}
+
+			require.Contains(t, rec.Body.String(), tc.body)
+			require.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+			if tc.status == http.StatusTooManyRequests {
+				require.NotEmpty(t, rec.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestRedirectHandler_Passthrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		link     storage.Link
+		target   string
+		status   int
+		location string
+	}{
+		{
+			name:     "Query dropped by default",
+			link:     storage.Link{URL: "https://example.com/docs"},
+			target:   "/abc?ref=newsletter",
+			status:   http.StatusFound,
+			location: "https://example.com/docs",
+		},
+		{
+			name:     "Query merged",
+			link:     storage.Link{URL: "https://example.com/docs?v=1", ForwardQuery: true},
+			target:   "/abc?ref=newsletter&q=a+b%26c",
+			status:   http.StatusFound,
+			location: "https://example.com/docs?v=1&ref=newsletter&q=a+b%26c",
+		},
+		{
+			name:     "Destination query wins",
+			link:     storage.Link{URL: "https://example.com/docs?ref=partner", ForwardQuery: true},
+			target:   "/abc?ref=newsletter&page=2",
+			status:   http.StatusFound,
+			location: "https://example.com/docs?ref=partner&page=2",
+		},
+		{
+			name:     "Path appended",
+			link:     storage.Link{URL: "https://example.com/docs/", ForwardPath: true},
+			target:   "/abc/guide/intro%20page",
+			status:   http.StatusFound,
+			location: "https://example.com/docs/guide/intro%20page",
+		},
+		{
+			name:     "Encoded slash kept",
+			link:     storage.Link{URL: "https://example.com/files", ForwardPath: true},
+			target:   "/abc/a%2Fb",
+			status:   http.StatusFound,
+			location: "https://example.com/files/a%2Fb",
+		},
+		{
+			name:     "Path and query",
+			link:     storage.Link{URL: "https://example.com/docs", ForwardQuery: true, ForwardPath: true},
+			target:   "/abc/guide?ref=x",
+			status:   http.StatusFound,
+			location: "https://example.com/docs/guide?ref=x",
+		},
+		{
+			name:     "Trailing slash only",
+			link:     storage.Link{URL: "https://example.com/docs"},
+			target:   "/abc/",
+			status:   http.StatusFound,
+			location: "https://example.com/docs",
+		},
+		{
+			name:   "Path passthrough disabled",
+			link:   storage.Link{URL: "https://example.com/docs"},
+			target: "/abc/guide",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "Dot segments rejected",
+			link:   storage.Link{URL: "https://example.com/public/", ForwardPath: true},
+			target: "/abc/%2e%2e/private",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "Password not forwarded",
+			link:     storage.Link{URL: "https://example.com/docs", ForwardQuery: true, PasswordHash: string(hash)},
+			target:   "/abc?password=secret&ref=x",
+			status:   http.StatusFound,
+			location: "https://example.com/docs?ref=x",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			link := tc.link
+			link.Alias = "abc"
+
+			linkResolverMock := mocks.NewLinkResolver(t)
+			linkResolverMock.On("ResolveLink", "abc").Return(link, nil).Once()
+
+			checkerMock := mocks.NewDestinationChecker(t)
+			checkerMock.On("Check", "example.com").Return(nil).Maybe()
+
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			clickRecorderMock.On("RecordClick", mock.Anything).Return(nil).Maybe()
+
+			handler := redirect.New(slogdiscard.NewDiscardLogger(), linkResolverMock, clickRecorderMock, checkerMock, throttle.New(5, time.Minute))
+
+			router := gin.New()
+			router.GET("/:alias", handler)
+			router.GET("/:alias/*path", handler)
+
+			req, err := http.NewRequest(http.MethodGet, tc.target, nil)
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.status, rec.Code)
+			require.Equal(t, tc.location, rec.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,484 @@
+package save
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+	"url-shortener/internal/audit"
+	"url-shortener/internal/config"
+	"url-shortener/internal/domainpolicy"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/alias"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/ssrf"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	maxBatchItems = 1000
+	// batchWorkers items of a batch are checked concurrently, as checking an
+	// item may resolve its host and hash its password.
+	batchWorkers = 8
+	// batchTimeout bounds checking all items of a batch.
+	batchTimeout = 10 * time.Second
+)
+
+const (
+	// ModeAtomic stores either every item of the batch or none of them.
+	ModeAtomic = "atomic"
+	// ModeBestEffort stores every valid item independently of the others.
+	ModeBestEffort = "best_effort"
+)
+
+// errUnchecked marks items whose destination could not be checked, for
+// instance because resolving its host timed out.
+var errUnchecked = errors.New("destination not checked")
+
+type BatchItemResult struct {
+	resp.Response
+	Index     int        `json:"index"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Rule is the domain policy rule or the unsafe destination reason that
+	// rejected the item.
+	Rule string `json:"rule,omitempty"`
+}
+
+type BatchResponse struct {
+	resp.Response
+	Results []BatchItemResult `json:"results"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=BatchURLSaver
+type BatchURLSaver interface {
+	SaveURL(link storage.Link, audit ...storage.AuditEntry) error
+	SaveURLs(links []storage.Link, audit []storage.AuditEntry) ([]error, error)
+}
+
+func NewBatch(
+	log *slog.Logger,
+	urlSaver BatchURLSaver,
+	aliases *alias.Allocator,
+	normalizer *urlnorm.Normalizer,
+	checker DestinationChecker,
+	guard URLGuard,
+	cfg *config.Config,
+) gin.HandlerFunc {
+	validate := validator.New()
+	aliases.Policy().Register(validate)
+
+	return func(c *gin.Context) {
+		const op = "handlers.url.save.NewBatch"
+
+		mode := c.DefaultQuery("mode", ModeAtomic)
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+			slog.String("mode", mode),
+		)
+
+		if mode != ModeAtomic && mode != ModeBestEffort {
+			log.Info("invalid batch mode")
+			c.JSON(http.StatusBadRequest, resp.Error("mode must be one of: atomic, best_effort"))
+			return
+		}
+
+		var reqs []Request
+		if err := c.ShouldBindJSON(&reqs); err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error("failed to decode request"))
+			return
+		}
+
+		if len(reqs) == 0 || len(reqs) > maxBatchItems {
+			log.Info("invalid batch size", slog.Int("items", len(reqs)))
+			c.JSON(http.StatusBadRequest, resp.Error(fmt.Sprintf("batch must contain 1 to %d items", maxBatchItems)))
+			return
+		}
+
+		log.Info("batch decoded", slog.Int("items", len(reqs)))
+
+		now := time.Now()
+		owner := auth.FromContext(c)
+
+		// check returns the link to save for req, or nil with result telling
+		// why req is invalid.
+		check := func(ctx context.Context, req Request, result *BatchItemResult) (*storage.Link, error) {
+			if err := validate.Struct(req); err != nil {
+				result.Response = resp.ValidationError(err.(validator.ValidationErrors))
+				return nil, nil
+			}
+
+			expiresAt, err := req.Expiry(now)
+			if err != nil {
+				result.Response = resp.Error(err.Error())
+				return nil, nil
+			}
+
+			canonical, err := normalizer.Normalize(req.URL)
+			if err != nil {
+				result.Response = resp.Error("field URL is not a valid URL")
+				return nil, nil
+			}
+
+			var unsafe *ssrf.Violation
+			err = guard.Check(ctx, canonical)
+			if errors.As(err, &unsafe) {
+				result.Response = resp.Error(unsafe.Error())
+				result.Rule = unsafe.Reason
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errUnchecked, err)
+			}
+
+			var violation *domainpolicy.Violation
+			err = checker.Check(storage.HostOf(canonical))
+			if errors.As(err, &violation) {
+				result.Response = resp.Error(violation.Error())
+				result.Rule = violation.Rule
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			passwordHash, err := req.PasswordHash()
+			if errors.Is(err, errPasswordTooLong) {
+				result.Response = resp.Error(err.Error())
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			return &storage.Link{
+				Alias:          aliases.Policy().Normalize(req.Alias),
+				URL:            req.URL,
+				CanonicalURL:   canonical,
+				Owner:          owner.Name,
+				OwnerID:        owner.UserID,
+				ExpiresAt:      expiresAt,
+				PasswordHash:   passwordHash,
+				RedirectStatus: req.redirectStatus(cfg),
+				ForwardQuery:   req.ForwardQuery,
+				ForwardPath:    req.ForwardPath,
+			}, nil
+		}
+
+		results := make([]BatchItemResult, len(reqs))
+		checked := make([]*storage.Link, len(reqs))
+		errs := make([]error, len(reqs))
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), batchTimeout)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		workers := make(chan struct{}, batchWorkers)
+
+		for i := range reqs {
+			if ctx.Err() != nil {
+				break
+			}
+
+			workers <- struct{}{}
+			wg.Add(1)
+
+			go func(i int) {
+				defer func() {
+					<-workers
+					wg.Done()
+				}()
+
+				checked[i], errs[i] = check(ctx, reqs[i], &results[i])
+			}(i)
+		}
+
+		wg.Wait()
+
+		// Items left unchecked when the time ran out have no result, so the
+		// batch is not saved at all.
+		if ctx.Err() != nil {
+			log.Error("batch checks did not finish in time", slog.Int("items", len(reqs)), sl.Err(ctx.Err()))
+			c.JSON(http.StatusServiceUnavailable, resp.Error("batch took too long to check, send fewer items"))
+			return
+		}
+
+		err := errors.Join(errs...)
+		if errors.Is(err, errUnchecked) {
+			log.Error("failed to check destinations", sl.Err(err))
+			c.JSON(http.StatusServiceUnavailable, resp.Error("failed to check destinations"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to check batch items", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to add urls"))
+			return
+		}
+
+		links := make([]storage.Link, 0, len(reqs))
+		indexes := make([]int, 0, len(reqs))
+		invalid := 0
+
+		for i, link := range checked {
+			results[i].Index = i
+
+			if link == nil {
+				invalid++
+				continue
+			}
+
+			links = append(links, *link)
+			indexes = append(indexes, i)
+		}
+
+		if mode == ModeAtomic {
+			saveAtomic(c, log, urlSaver, aliases, results, links, indexes, invalid)
+			return
+		}
+
+		saveBestEffort(c, log, urlSaver, aliases, results, links, indexes, invalid)
+	}
+}
+
+func saveAtomic(
+	c *gin.Context,
+	log *slog.Logger,
+	urlSaver BatchURLSaver,
+	aliases *alias.Allocator,
+	results []BatchItemResult,
+	links []storage.Link,
+	indexes []int,
+	invalid int,
+) {
+	const notSaved = "not saved: another item of the batch failed"
+
+	if invalid > 0 {
+		for _, i := range indexes {
+			results[i].Response = resp.Error(notSaved)
+		}
+
+		log.Info("batch rejected", slog.Int("invalid", invalid))
+		c.JSON(http.StatusBadRequest, BatchResponse{
+			Response: resp.Error(fmt.Sprintf("%d of %d items are invalid", invalid, len(results))),
+			Results:  results,
+		})
+		return
+	}
+
+	errs, err := saveGenerated(c, log, urlSaver, aliases, links)
+	if errors.Is(err, alias.ErrAttemptsExhausted) {
+		log.Error("failed to generate aliases", sl.Err(err))
+		c.JSON(http.StatusInternalServerError, resp.Error("failed to generate aliases"))
+		return
+	}
+	if err != nil {
+		log.Error("failed to add urls", sl.Err(err))
+		c.JSON(http.StatusInternalServerError, resp.Error("failed to add urls"))
+		return
+	}
+
+	conflicts := 0
+	for j, i := range indexes {
+		switch {
+		case errors.Is(errs[j], storage.ErrURLExists):
+			results[i].Response = resp.Error("url already exists")
+			conflicts++
+		case errs[j] != nil:
+			results[i].Response = resp.Error("failed to add url")
+			conflicts++
+		default:
+			results[i].Response = resp.OK()
+			results[i].Alias = links[j].Alias
+			results[i].ExpiresAt = links[j].ExpiresAt
+		}
+	}
+
+	if conflicts > 0 {
+		for j, i := range indexes {
+			if errs[j] == nil {
+				results[i].Response = resp.Error(notSaved)
+				results[i].Alias = ""
+				results[i].ExpiresAt = nil
+			}
+		}
+
+		log.Info("batch rejected", slog.Int("conflicts", conflicts))
+		c.JSON(http.StatusConflict, BatchResponse{
+			Response: resp.Error(fmt.Sprintf("%d of %d items conflict with existing aliases", conflicts, len(results))),
+			Results:  results,
+		})
+		return
+	}
+
+	log.Info("urls added", slog.Int("items", len(links)))
+
+	c.JSON(http.StatusOK, BatchResponse{
+		Response: resp.OK(),
+		Results:  results,
+	})
+}
+
+func saveBestEffort(
+	c *gin.Context,
+	log *slog.Logger,
+	urlSaver BatchURLSaver,
+	aliases *alias.Allocator,
+	results []BatchItemResult,
+	links []storage.Link,
+	indexes []int,
+	invalid int,
+) {
+	failed := invalid
+
+	for j, i := range indexes {
+		var err error
+		if links[j].Alias != "" {
+			err = saveAudited(c, urlSaver, links[j])
+		} else {
+			links[j].Generated = true
+
+			var res alias.Result
+			res, err = aliases.Save(func(generated string) error {
+				links[j].Alias = generated
+				return saveAudited(c, urlSaver, links[j])
+			})
+			if err == nil {
+				log.Info("alias generated",
+					slog.Int("index", i),
+					slog.String("strategy", string(res.Strategy)),
+					slog.Int("attempts", res.Attempts),
+				)
+			}
+		}
+		if errors.Is(err, storage.ErrURLExists) {
+			results[i].Response = resp.Error("url already exists")
+			failed++
+			continue
+		}
+		if errors.Is(err, alias.ErrAttemptsExhausted) {
+			log.Error("failed to generate alias", sl.Err(err), slog.Int("index", i))
+			results[i].Response = resp.Error("failed to generate alias")
+			failed++
+			continue
+		}
+		if err != nil {
+			log.Error("failed to add url", sl.Err(err), slog.Int("index", i))
+			results[i].Response = resp.Error("failed to add url")
+			failed++
+			continue
+		}
+
+		results[i].Response = resp.OK()
+		results[i].Alias = links[j].Alias
+		results[i].ExpiresAt = links[j].ExpiresAt
+	}
+
+	log.Info("urls added", slog.Int("items", len(results)-failed), slog.Int("failed", failed))
+
+	if failed > 0 {
+		c.JSON(http.StatusMultiStatus, BatchResponse{
+			Response: resp.Error(fmt.Sprintf("%d of %d items failed", failed, len(results))),
+			Results:  results,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, BatchResponse{
+		Response: resp.OK(),
+		Results:  results,
+	})
+}
+
+// saveGenerated stores links in one transaction, generating aliases for the
+// links that have none. Transactions that fail only because a generated alias
+// is taken are retried with fresh aliases for the colliding links. The audit
+// entries of the links are stored in the same transaction.
+func saveGenerated(c *gin.Context, log *slog.Logger, urlSaver BatchURLSaver, aliases *alias.Allocator, links []storage.Link) ([]error, error) {
+	const op = "handlers.url.save.saveGenerated"
+
+	generated := make([]bool, len(links))
+	for j := range links {
+		generated[j] = links[j].Alias == ""
+		links[j].Generated = generated[j]
+	}
+
+	for attempt := 1; ; attempt++ {
+		pending := 0
+		strategy := alias.StrategyFirst
+
+		for j := range links {
+			if !generated[j] || links[j].Alias != "" {
+				continue
+			}
+
+			a, s, err := aliases.Generate(attempt)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+
+			links[j].Alias = a
+			strategy = s
+			pending++
+		}
+
+		if pending > 0 {
+			log.Info("aliases generated",
+				slog.Int("count", pending),
+				slog.String("strategy", string(strategy)),
+				slog.Int("attempt", attempt),
+			)
+		}
+
+		entries := make([]storage.AuditEntry, len(links))
+		for j, link := range links {
+			entry, err := audit.Entry(c, storage.ActionLinkCreate, link.Alias, nil, link.AuditState())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+
+			entries[j] = entry
+		}
+
+		errs, err := urlSaver.SaveURLs(links, entries)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		collisions, conflicts := 0, 0
+		for j, e := range errs {
+			switch {
+			case e == nil:
+			case generated[j] && errors.Is(e, storage.ErrURLExists):
+				collisions++
+			default:
+				conflicts++
+			}
+		}
+
+		aliases.Observe(pending, collisions)
+
+		if collisions == 0 || conflicts > 0 {
+			return errs, nil
+		}
+		if attempt >= aliases.MaxAttempts() {
+			return nil, fmt.Errorf("%s: %w after %d attempts", op, alias.ErrAttemptsExhausted, attempt)
+		}
+
+		for j, e := range errs {
+			if e != nil {
+				links[j].Alias = ""
+			}
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package save_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/http-server/handlers/save"
+	"url-shortener/internal/http-server/handlers/save/mocks"
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	aliases := alias.New(slogdiscard.NewDiscardLogger(), alias.Options{
+		Length:      8,
+		MaxAttempts: 3,
+	})
+
+	cfg := &config.Config{}
+	cfg.Redirect.DefaultStatus = http.StatusTemporaryRedirect
+
+	valid := []save.Request{
+		{URL: "https://example.com/1", Alias: "first"},
+		{URL: "https://example.com/2", Alias: "second", RedirectStatus: http.StatusMovedPermanently},
+	}
+
+	cases := []struct {
+		name      string
+		mode      string
+		body      any
+		setup     func(m *mocks.BatchURLSaver)
+		status    int
+		respError string
+		items     []string
+	}{
+		{
+			name: "Atomic success",
+			body: valid,
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURLs", mock.MatchedBy(func(links []storage.Link) bool {
+					return len(links) == 2 && links[0].Alias == "first" && links[1].Alias == "second" &&
+						links[0].CanonicalURL == "https://example.com/1" &&
+						links[0].RedirectStatus == http.StatusTemporaryRedirect &&
+						links[1].RedirectStatus == http.StatusMovedPermanently &&
+						links[0].OwnerID == 5 && links[1].Owner == "pedro"
+				}), mock.Anything).Return([]error{nil, nil}, nil).Once()
+			},
+			status: http.StatusOK,
+			items:  []string{"first", "second"},
+		},
+		{
+			name: "Atomic generates aliases",
+			body: []save.Request{{URL: "https://example.com"}},
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURLs", mock.MatchedBy(func(links []storage.Link) bool {
+					return len(links) == 1 && len(links[0].Alias) == 8
+				}), mock.Anything).Return([]error{nil}, nil).Once()
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "Atomic retries generated collisions",
+			body: []save.Request{valid[0], {URL: "https://example.com"}},
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURLs", mock.Anything, mock.Anything).Return([]error{nil, storage.ErrURLExists}, nil).Twice()
+				m.On("SaveURLs", mock.MatchedBy(func(links []storage.Link) bool {
+					return links[0].Alias == "first" && len(links[1].Alias) == 8
+				}), mock.Anything).Return([]error{nil, nil}, nil).Once()
+			},
+			status: http.StatusOK,
+			items:  []string{"first"},
+		},
+		{
+			name: "Atomic generated attempts exhausted",
+			body: []save.Request{{URL: "https://example.com"}},
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURLs", mock.Anything, mock.Anything).Return([]error{storage.ErrURLExists}, nil).Times(3)
+			},
+			status:    http.StatusInternalServerError,
+			respError: "failed to generate aliases",
+		},
+		{
+			name: "Atomic custom conflict is not retried",
+			body: []save.Request{valid[0], {URL: "https://example.com"}},
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURLs", mock.Anything, mock.Anything).Return([]error{storage.ErrURLExists, storage.ErrURLExists}, nil).Once()
+			},
+			status:    http.StatusConflict,
+			respError: "2 of 2 items conflict with existing aliases",
+			items:     []string{"url already exists", "url already exists"},
+		},
+		{
+			name:      "Atomic invalid item",
+			body:      []save.Request{valid[0], {URL: "invalid-url", Alias: "bad"}},
+			status:    http.StatusBadRequest,
+			respError: "1 of 2 items are invalid",
+			items:     []string{"not saved: another item of the batch failed", "field URL is not a valid URL"},
+		},
+		{
+			name:      "Atomic blocked destination",
+			body:      []save.Request{valid[0], {URL: "https://phish.example", Alias: "bad"}},
+			status:    http.StatusBadRequest,
+			respError: "1 of 2 items are invalid",
+			items:     []string{"not saved: another item of the batch failed", `host phish.example is blocked by rule "phish.example"`},
+		},
+		{
+			name:      "Unchecked destination",
+			mode:      save.ModeBestEffort,
+			body:      []save.Request{valid[0], {URL: "https://slow.example", Alias: "slow"}},
+			status:    http.StatusServiceUnavailable,
+			respError: "failed to check destinations",
+		},
+		{
+			name: "Best effort private destination",
+			mode: save.ModeBestEffort,
+			body: []save.Request{{URL: "http://127.0.0.1:8080", Alias: "local"}, valid[0]},
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURL", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			status:    http.StatusMultiStatus,
+			respError: "1 of 2 items failed",
+			items:     []string{"host 127.0.0.1 resolves to non-public address 127.0.0.1", "first"},
+		},
+		{
+			name: "Atomic conflict",
+			body: valid,
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURLs", mock.Anything, mock.Anything).Return([]error{nil, storage.ErrURLExists}, nil).Once()
+			},
+			status:    http.StatusConflict,
+			respError: "1 of 2 items conflict with existing aliases",
+			items:     []string{"not saved: another item of the batch failed", "url already exists"},
+		},
+		{
+			name: "Atomic internal error",
+			body: valid,
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURLs", mock.Anything, mock.Anything).Return(nil, errors.New("internal error")).Once()
+			},
+			status:    http.StatusInternalServerError,
+			respError: "failed to add urls",
+		},
+		{
+			name: "Best effort partial",
+			mode: save.ModeBestEffort,
+			body: []save.Request{valid[0], valid[1], {URL: "invalid-url", Alias: "bad"}},
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURL", mock.MatchedBy(func(link storage.Link) bool { return link.Alias == "first" }), mock.Anything).
+					Return(nil).Once()
+				m.On("SaveURL", mock.MatchedBy(func(link storage.Link) bool { return link.Alias == "second" }), mock.Anything).
+					Return(storage.ErrURLExists).Once()
+			},
+			status:    http.StatusMultiStatus,
+			respError: "2 of 3 items failed",
+			items:     []string{"first", "url already exists", "field URL is not a valid URL"},
+		},
+		{
+			name: "Best effort retries generated collisions",
+			mode: save.ModeBestEffort,
+			body: []save.Request{{URL: "https://example.com"}},
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURL", mock.Anything, mock.Anything).Return(storage.ErrURLExists).Once()
+				m.On("SaveURL", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "Best effort success",
+			mode: save.ModeBestEffort,
+			body: valid,
+			setup: func(m *mocks.BatchURLSaver) {
+				m.On("SaveURL", mock.Anything, mock.Anything).Return(nil).Twice()
+			},
+			status: http.StatusOK,
+			items:  []string{"first", "second"},
+		},
+		{
+			name:      "Invalid mode",
+			mode:      "sometimes",
+			body:      valid,
+			status:    http.StatusBadRequest,
+			respError: "mode must be one of: atomic, best_effort",
+		},
+		{
+			name:      "Empty batch",
+			body:      []save.Request{},
+			status:    http.StatusBadRequest,
+			respError: "batch must contain 1 to 1000 items",
+		},
+		{
+			name:      "Not an array",
+			body:      valid[0],
+			status:    http.StatusBadRequest,
+			respError: "failed to decode request",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			batchSaverMock := mocks.NewBatchURLSaver(t)
+
+			if tc.setup != nil {
+				tc.setup(batchSaverMock)
+			}
+
+			router := gin.New()
+			router.Use(withPrincipal)
+			router.POST("/api/save/batch", save.NewBatch(slogdiscard.NewDiscardLogger(), batchSaverMock, aliases, urlnorm.New(urlnorm.Options{}), newDestinationChecker(t), newURLGuard(t), cfg))
+
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			target := "/api/save/batch"
+			if tc.mode != "" {
+				target += "?mode=" + tc.mode
+			}
+
+			req, err := http.NewRequest(http.MethodPost, target, bytes.NewBuffer(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			var res save.BatchResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+			require.Equal(t, tc.respError, res.Error)
+
+			for i, want := range tc.items {
+				got := res.Results[i].Alias
+				if res.Results[i].Error != "" {
+					got = res.Results[i].Error
+				}
+				require.Equal(t, want, got)
+			}
+
+			// Every saved link is handed to the storage with its audit entry.
+			audited := map[string]bool{}
+			for _, call := range batchSaverMock.Calls {
+				var entries []storage.AuditEntry
+
+				switch call.Method {
+				case "SaveURL":
+					for _, arg := range call.Arguments[1:] {
+						entries = append(entries, arg.(storage.AuditEntry))
+					}
+				case "SaveURLs":
+					entries = call.Arguments.Get(1).([]storage.AuditEntry)
+					require.Len(t, entries, len(call.Arguments.Get(0).([]storage.Link)))
+				}
+
+				for _, entry := range entries {
+					require.Equal(t, storage.ActionLinkCreate, entry.Action)
+					audited[entry.Alias] = true
+				}
+			}
+			for _, result := range res.Results {
+				if result.Error == "" {
+					require.True(t, audited[result.Alias], result.Alias)
+				}
+			}
+		})
+	}
+}
+
+// TestBatchHandler_ChecksCancelled keeps batches whose checks did not finish
+// from being saved, as the guard lets unresolved hosts through.
+func TestBatchHandler_ChecksCancelled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	aliases := alias.New(slogdiscard.NewDiscardLogger(), alias.Options{Length: 8})
+
+	router := gin.New()
+	router.Use(withPrincipal)
+	router.POST("/api/save/batch", save.NewBatch(slogdiscard.NewDiscardLogger(), mocks.NewBatchURLSaver(t), aliases, urlnorm.New(urlnorm.Options{}), newDestinationChecker(t), newURLGuard(t), &config.Config{}))
+
+	body, err := json.Marshal([]save.Request{{URL: "https://example.com/1", Alias: "first"}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/api/save/batch", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
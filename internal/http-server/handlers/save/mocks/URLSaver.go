@@ -2,20 +2,55 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
 
 // URLSaver is an autogenerated mock type for the URLSaver type
 type URLSaver struct {
 	mock.Mock
 }
 
-// SaveURL provides a mock function with given fields: urlToSave, alias
-func (_m *URLSaver) SaveURL(urlToSave string, alias string) error {
-	ret := _m.Called(urlToSave, alias)
+// FindGeneratedLink provides a mock function with given fields: ownerID, url
+func (_m *URLSaver) FindGeneratedLink(ownerID int64, url string) (storage.Link, error) {
+	ret := _m.Called(ownerID, url)
+
+	var r0 storage.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, string) (storage.Link, error)); ok {
+		return rf(ownerID, url)
+	}
+	if rf, ok := ret.Get(0).(func(int64, string) storage.Link); ok {
+		r0 = rf(ownerID, url)
+	} else {
+		r0 = ret.Get(0).(storage.Link)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, string) error); ok {
+		r1 = rf(ownerID, url)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveURL provides a mock function with given fields: link, audit
+func (_m *URLSaver) SaveURL(link storage.Link, audit ...storage.AuditEntry) error {
+	_va := make([]interface{}, len(audit))
+	for _i := range audit {
+		_va[_i] = audit[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, link)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string) error); ok {
-		r0 = rf(urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(storage.Link, ...storage.AuditEntry) error); ok {
+		r0 = rf(link, audit...)
 	} else {
 		r0 = ret.Error(0)
 	}
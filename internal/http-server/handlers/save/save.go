@@ -1,41 +1,149 @@
 package save
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
+	"url-shortener/internal/audit"
 	"url-shortener/internal/config"
+	"url-shortener/internal/domainpolicy"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/alias"
 	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/expiry"
 	"url-shortener/internal/lib/logger/sl"
-	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/ssrf"
 	"url-shortener/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Request struct {
-	URL   string `json:"url" validate:"required,url"`
-	Alias string `json:"alias,omitempty"`
+	URL       string     `json:"url" validate:"required,url"`
+	Alias     string     `json:"alias,omitempty" validate:"omitempty,alias"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" validate:"omitempty,excluded_with=TTL"`
+	TTL       string     `json:"ttl,omitempty" validate:"omitempty,excluded_with=ExpiresAt"`
+	// Password protects the link, visitors have to enter it before being
+	// redirected. bcrypt only uses the first 72 bytes.
+	Password string `json:"password,omitempty" validate:"omitempty,max=72"`
+	// RedirectStatus is the status code visitors are redirected with,
+	// redirect.default_status when omitted.
+	RedirectStatus int `json:"redirect_status,omitempty" validate:"omitempty,oneof=301 302 303 307 308"`
+	// ForwardQuery merges the query string of a visit into the destination.
+	ForwardQuery bool `json:"forward_query,omitempty"`
+	// ForwardPath appends the path below the alias of a visit to the
+	// destination.
+	ForwardPath bool `json:"forward_path,omitempty"`
 }
 
 type Response struct {
 	resp.Response
-	Alias string `json:"alias,omitempty"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Reused is set when deduplication returned an existing link instead
+	// of creating a new one.
+	Reused bool `json:"reused,omitempty"`
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=URLSaver
 type URLSaver interface {
-	SaveURL(urlToSave string, alias string) error
+	SaveURL(link storage.Link, audit ...storage.AuditEntry) error
+	FindGeneratedLink(ownerID int64, url string) (storage.Link, error)
 }
 
-func New(log *slog.Logger, urlSaver URLSaver, cfg *config.Config) gin.HandlerFunc {
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=DestinationChecker
+type DestinationChecker interface {
+	Check(host string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=URLGuard
+type URLGuard interface {
+	Check(ctx context.Context, rawURL string) error
+}
+
+// errPasswordTooLong is returned for passwords within the character limit
+// that exceed bcrypt's byte limit.
+var errPasswordTooLong = errors.New("field Password must be at most 72 bytes long")
+
+// LogValue keeps the password out of the logs.
+func (r Request) LogValue() slog.Value {
+	type request Request
+
+	if r.Password != "" {
+		r.Password = "[redacted]"
+	}
+
+	return slog.AnyValue(request(r))
+}
+
+// PasswordHash returns the bcrypt hash of the requested password, or an empty
+// string for public links.
+func (r Request) PasswordHash() (string, error) {
+	if r.Password == "" {
+		return "", nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(r.Password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", errPasswordTooLong
+	}
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func (r Request) redirectStatus(cfg *config.Config) int {
+	if r.RedirectStatus != 0 {
+		return r.RedirectStatus
+	}
+	return cfg.Redirect.DefaultStatus
+}
+
+// reusable reports whether existing redirects visitors the same way link would.
+// Visitors are sent to the stored URL, which keeps the query parameters the
+// canonical URL drops, so with dropParams the stored URLs must match too.
+func reusable(existing, link storage.Link, dropParams bool) bool {
+	if dropParams && existing.URL != link.URL {
+		return false
+	}
+
+	return existing.StatusCode() == link.StatusCode() &&
+		existing.ForwardQuery == link.ForwardQuery &&
+		existing.ForwardPath == link.ForwardPath
+}
+
+// Expiry returns the absolute expiry time requested either via ExpiresAt or
+// TTL, or nil when the link should never expire.
+func (r Request) Expiry(now time.Time) (*time.Time, error) {
+	return expiry.Resolve(r.ExpiresAt, r.TTL, now)
+}
+
+func New(
+	log *slog.Logger,
+	urlSaver URLSaver,
+	aliases *alias.Allocator,
+	normalizer *urlnorm.Normalizer,
+	checker DestinationChecker,
+	guard URLGuard,
+	cfg *config.Config,
+) gin.HandlerFunc {
+	validate := validator.New()
+	aliases.Policy().Register(validate)
+
 	return func(c *gin.Context) {
 		const op = "handlers.url.save.New"
 
 		requestID := c.GetString("request_id")
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", requestID),
 		)
@@ -49,7 +157,6 @@ func New(log *slog.Logger, urlSaver URLSaver, cfg *config.Config) gin.HandlerFun
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		validate := validator.New()
 		if err := validate.Struct(req); err != nil {
 			validateErr := err.(validator.ValidationErrors)
 			log.Error("invalid request", sl.Err(err))
@@ -57,28 +164,146 @@ func New(log *slog.Logger, urlSaver URLSaver, cfg *config.Config) gin.HandlerFun
 			return
 		}
 
-		alias := req.Alias
-		if alias == "" {
-			alias = random.NewRandomString(cfg.AliasLength)
+		expiresAt, err := req.Expiry(time.Now())
+		if err != nil {
+			log.Error("invalid expiry", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		canonical, err := normalizer.Normalize(req.URL)
+		if err != nil {
+			log.Error("failed to normalize url", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error("field URL is not a valid URL"))
+			return
 		}
 
-		err := urlSaver.SaveURL(req.URL, alias)
+		var unsafe *ssrf.Violation
+		err = guard.Check(c.Request.Context(), canonical)
+		if errors.As(err, &unsafe) {
+			log.Info("unsafe destination rejected", slog.String("reason", unsafe.Reason))
+			c.JSON(http.StatusUnprocessableEntity, resp.Violation(unsafe.Error(), unsafe.Reason))
+			return
+		}
+		if err != nil {
+			log.Error("failed to check destination", sl.Err(err))
+			c.JSON(http.StatusServiceUnavailable, resp.Error("failed to check destination"))
+			return
+		}
+
+		var violation *domainpolicy.Violation
+		err = checker.Check(storage.HostOf(canonical))
+		if errors.As(err, &violation) {
+			log.Info("destination rejected by domain policy", slog.String("rule", violation.Rule))
+			c.JSON(http.StatusUnprocessableEntity, resp.Violation(violation.Error(), violation.Rule))
+			return
+		}
+		if err != nil {
+			log.Error("failed to check domain policy", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to add url"))
+			return
+		}
+
+		passwordHash, err := req.PasswordHash()
+		if errors.Is(err, errPasswordTooLong) {
+			log.Info("password too long")
+			c.JSON(http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		if err != nil {
+			log.Error("failed to hash password", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to add url"))
+			return
+		}
+
+		owner := auth.FromContext(c)
+
+		link := storage.Link{
+			Alias:          aliases.Policy().Normalize(req.Alias),
+			URL:            req.URL,
+			CanonicalURL:   canonical,
+			Owner:          owner.Name,
+			OwnerID:        owner.UserID,
+			ExpiresAt:      expiresAt,
+			PasswordHash:   passwordHash,
+			RedirectStatus: req.redirectStatus(cfg),
+			ForwardQuery:   req.ForwardQuery,
+			ForwardPath:    req.ForwardPath,
+		}
+
+		if link.Alias == "" && link.ExpiresAt == nil && link.PasswordHash == "" && cfg.Alias.Dedupe {
+			existing, err := urlSaver.FindGeneratedLink(link.OwnerID, link.CanonicalURL)
+			if err == nil && reusable(existing, link, len(cfg.Normalize.DropParams) > 0) {
+				log.Info("existing alias reused", slog.String("alias", existing.Alias))
+
+				c.JSON(http.StatusOK, Response{
+					Response: resp.OK(),
+					Alias:    existing.Alias,
+					Reused:   true,
+				})
+				return
+			}
+			if err != nil && !errors.Is(err, storage.ErrURLNotFound) {
+				log.Error("failed to look up existing alias", sl.Err(err))
+				c.JSON(http.StatusInternalServerError, resp.Error("failed to add url"))
+				return
+			}
+		}
+
+		if link.Alias != "" {
+			err = saveAudited(c, urlSaver, link)
+		} else {
+			link.Generated = true
+
+			var res alias.Result
+			res, err = aliases.Save(func(generated string) error {
+				link.Alias = generated
+				return saveAudited(c, urlSaver, link)
+			})
+			if err == nil {
+				log.Info("alias generated",
+					slog.String("strategy", string(res.Strategy)),
+					slog.Int("attempts", res.Attempts),
+				)
+			}
+		}
 		if errors.Is(err, storage.ErrURLExists) {
 			log.Info("url already exists", slog.String("url", req.URL))
 			c.JSON(http.StatusConflict, resp.Error("url already exists"))
 			return
 		}
+		if errors.Is(err, alias.ErrAttemptsExhausted) {
+			log.Error("failed to generate alias", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to generate alias"))
+			return
+		}
 		if err != nil {
 			log.Error("failed to add url", sl.Err(err))
 			c.JSON(http.StatusInternalServerError, resp.Error("failed to add url"))
 			return
 		}
 
-		log.Info("url added", slog.String("alias", alias))
+		log.Info("url added", slog.String("alias", link.Alias))
 
 		c.JSON(http.StatusOK, Response{
-			Response: resp.OK(),
-			Alias:    alias,
+			Response:  resp.OK(),
+			Alias:     link.Alias,
+			ExpiresAt: expiresAt,
 		})
 	}
 }
+
+// linkSaver is the part of URLSaver and BatchURLSaver saving single links.
+type linkSaver interface {
+	SaveURL(link storage.Link, audit ...storage.AuditEntry) error
+}
+
+// saveAudited saves link together with the audit entry of its creation.
+func saveAudited(c *gin.Context, urlSaver linkSaver, link storage.Link) error {
+	entry, err := audit.Entry(c, storage.ActionLinkCreate, link.Alias, nil, link.AuditState())
+	if err != nil {
+		return err
+	}
+
+	return urlSaver.SaveURL(link, entry)
+}
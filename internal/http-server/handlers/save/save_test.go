@@ -6,24 +6,52 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"url-shortener/internal/config"
+	"url-shortener/internal/domainpolicy"
 	"url-shortener/internal/http-server/handlers/save"
 	"url-shortener/internal/http-server/handlers/save/mocks"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/alias"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/ssrf"
 	"url-shortener/internal/storage"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestSaveHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	cfg := &config.Config{
-		AliasLength: 8,
-	}
+	normalizer := urlnorm.New(urlnorm.Options{DropParams: []string{"utm_*"}})
+
+	policy, err := alias.NewPolicy(alias.PolicyOptions{
+		Charset:       "a-zA-Z0-9_-",
+		MinLength:     3,
+		MaxLength:     32,
+		CaseSensitive: true,
+		Reserved:      []string{"save", "links"},
+	})
+	require.NoError(t, err)
+
+	aliases := alias.New(slogdiscard.NewDiscardLogger(), alias.Options{
+		Length:      8,
+		MaxAttempts: 3,
+		Policy:      policy,
+	})
+
+	cfg := &config.Config{}
+	cfg.Redirect.DefaultStatus = http.StatusFound
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
 
 	cases := []struct {
 		name      string
@@ -31,6 +59,10 @@ func TestSaveHandler(t *testing.T) {
 		respError string
 		mockError error
 		status    int
+		skipSave  bool
+		expires   bool
+		canonical string
+		redirect  int
 	}{
 		{
 			name: "Success",
@@ -41,6 +73,78 @@ func TestSaveHandler(t *testing.T) {
 			respError: "",
 			mockError: nil,
 			status:    http.StatusOK,
+			redirect:  http.StatusFound,
+		},
+		{
+			name: "Permanent redirect",
+			request: save.Request{
+				URL:            "https://example.com",
+				Alias:          "test_alias",
+				RedirectStatus: http.StatusMovedPermanently,
+			},
+			status:   http.StatusOK,
+			redirect: http.StatusMovedPermanently,
+		},
+		{
+			name: "With passthrough",
+			request: save.Request{
+				URL:          "https://example.com",
+				Alias:        "test_alias",
+				ForwardQuery: true,
+				ForwardPath:  true,
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "Invalid redirect status",
+			request: save.Request{
+				URL:            "https://example.com",
+				Alias:          "test_alias",
+				RedirectStatus: http.StatusNotModified,
+			},
+			respError: "field RedirectStatus must be one of: 301, 302, 303, 307, 308",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "With password",
+			request: save.Request{
+				URL:      "https://example.com",
+				Alias:    "locked",
+				Password: "correct horse battery staple",
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "Password too long",
+			request: save.Request{
+				URL:      "https://example.com",
+				Alias:    "locked",
+				Password: strings.Repeat("p", 73),
+			},
+			respError: "field Password must be at most 72 characters long",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "Password over the byte limit",
+			request: save.Request{
+				URL:      "https://example.com",
+				Alias:    "locked",
+				Password: strings.Repeat("п", 40),
+			},
+			respError: "field Password must be at most 72 bytes long",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "Canonical URL",
+			request: save.Request{
+				URL:   "HTTPS://Example.com:443/a/./b?utm_source=mail&id=1",
+				Alias: "canonical",
+			},
+			status:    http.StatusOK,
+			canonical: "https://example.com/a/b?id=1",
 		},
 		{
 			name: "URL already exists",
@@ -61,6 +165,37 @@ func TestSaveHandler(t *testing.T) {
 			respError: "field URL is not a valid URL",
 			mockError: nil,
 			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "Blocked destination",
+			request: save.Request{
+				URL:   "https://phish.example/login",
+				Alias: "test",
+			},
+			respError: `"rule":"phish.example"`,
+			status:    http.StatusUnprocessableEntity,
+			skipSave:  true,
+		},
+		{
+			name: "Private destination",
+			request: save.Request{
+				URL:   "http://127.0.0.1:8080",
+				Alias: "test",
+			},
+			respError: `"rule":"private_address"`,
+			status:    http.StatusUnprocessableEntity,
+			skipSave:  true,
+		},
+		{
+			name: "Unchecked destination",
+			request: save.Request{
+				URL:   "https://slow.example",
+				Alias: "test",
+			},
+			respError: "failed to check destination",
+			status:    http.StatusServiceUnavailable,
+			skipSave:  true,
 		},
 		{
 			name: "Internal error",
@@ -72,6 +207,100 @@ func TestSaveHandler(t *testing.T) {
 			mockError: errors.New("internal error"),
 			status:    http.StatusInternalServerError,
 		},
+		{
+			name: "With TTL",
+			request: save.Request{
+				URL:   "https://example.com",
+				Alias: "ttl_alias",
+				TTL:   "24h",
+			},
+			status:  http.StatusOK,
+			expires: true,
+		},
+		{
+			name: "With expiry time",
+			request: save.Request{
+				URL:       "https://example.com",
+				Alias:     "expiring_alias",
+				ExpiresAt: &future,
+			},
+			status:  http.StatusOK,
+			expires: true,
+		},
+		{
+			name: "Alias with invalid characters",
+			request: save.Request{
+				URL:   "https://example.com",
+				Alias: "my alias/1",
+			},
+			respError: "field Alias may only contain characters [a-zA-Z0-9_-]",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "Alias too short",
+			request: save.Request{
+				URL:   "https://example.com",
+				Alias: "ab",
+			},
+			respError: "field Alias must be at least 3 characters long",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "Alias too long",
+			request: save.Request{
+				URL:   "https://example.com",
+				Alias: "abcdefghijklmnopqrstuvwxyz0123456789",
+			},
+			respError: "field Alias must be at most 32 characters long",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "Reserved alias",
+			request: save.Request{
+				URL:   "https://example.com",
+				Alias: "links",
+			},
+			respError: "field Alias is reserved",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "Invalid TTL",
+			request: save.Request{
+				URL:   "https://example.com",
+				Alias: "test",
+				TTL:   "-1h",
+			},
+			respError: "field TTL must be a positive duration",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "Expiry in the past",
+			request: save.Request{
+				URL:       "https://example.com",
+				Alias:     "test",
+				ExpiresAt: &past,
+			},
+			respError: "field ExpiresAt must be in the future",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
+		{
+			name: "Both expiry and TTL",
+			request: save.Request{
+				URL:       "https://example.com",
+				Alias:     "test",
+				ExpiresAt: &future,
+				TTL:       "1h",
+			},
+			respError: "field ExpiresAt cannot be used together with TTL",
+			status:    http.StatusBadRequest,
+			skipSave:  true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -81,14 +310,31 @@ func TestSaveHandler(t *testing.T) {
 			t.Parallel()
 			urlSaverMock := mocks.NewURLSaver(t)
 
-			if tc.mockError != nil && tc.name != "Invalid URL" {
-				urlSaverMock.On("SaveURL", tc.request.URL, tc.request.Alias).Return(tc.mockError).Once()
-			} else if tc.mockError == nil && tc.name != "Invalid URL" {
-				urlSaverMock.On("SaveURL", tc.request.URL, tc.request.Alias).Return(nil).Once()
+			if !tc.skipSave {
+				urlSaverMock.On("SaveURL", mock.MatchedBy(func(link storage.Link) bool {
+					return link.URL == tc.request.URL &&
+						(tc.canonical == "" || link.CanonicalURL == tc.canonical) &&
+						link.Alias == tc.request.Alias &&
+						(link.ExpiresAt != nil) == tc.expires &&
+						passwordMatches(link.PasswordHash, tc.request.Password) &&
+						(tc.redirect == 0 || link.RedirectStatus == tc.redirect) &&
+						link.ForwardQuery == tc.request.ForwardQuery &&
+						link.ForwardPath == tc.request.ForwardPath &&
+						link.Owner == "pedro" && link.OwnerID == 5
+				}), mock.MatchedBy(func(entry storage.AuditEntry) bool {
+					var state storage.LinkState
+					return entry.Action == storage.ActionLinkCreate &&
+						entry.Alias == tc.request.Alias && entry.Before == "" &&
+						json.Unmarshal([]byte(entry.After), &state) == nil &&
+						state.URL == tc.request.URL &&
+						state.Owner == "pedro" &&
+						state.PasswordProtected == (tc.request.Password != "")
+				})).Return(tc.mockError).Once()
 			}
 
 			router := gin.New()
-			router.POST("/api/save", save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, cfg))
+			router.Use(withPrincipal)
+			router.POST("/api/save", save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, aliases, normalizer, newDestinationChecker(t), newURLGuard(t), cfg))
 
 			body, err := json.Marshal(tc.request)
 			require.NoError(t, err)
@@ -112,3 +358,316 @@ func TestSaveHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveHandler_GeneratedAlias(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	normalizer := urlnorm.New(urlnorm.Options{})
+
+	cases := []struct {
+		name       string
+		collisions int
+		respError  string
+		status     int
+	}{
+		{
+			name:   "First attempt",
+			status: http.StatusOK,
+		},
+		{
+			name:       "Retried after collisions",
+			collisions: 2,
+			status:     http.StatusOK,
+		},
+		{
+			name:       "Attempts exhausted",
+			collisions: 3,
+			respError:  "failed to generate alias",
+			status:     http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			urlSaverMock := mocks.NewURLSaver(t)
+
+			aliases := alias.New(slogdiscard.NewDiscardLogger(), alias.Options{
+				Length:      8,
+				MaxLength:   10,
+				MaxAttempts: 3,
+			})
+
+			generated := mock.MatchedBy(func(link storage.Link) bool {
+				return len(link.Alias) >= 8
+			})
+
+			if tc.collisions > 0 {
+				urlSaverMock.On("SaveURL", generated, mock.Anything).Return(storage.ErrURLExists).Times(tc.collisions)
+			}
+
+			if tc.collisions < aliases.MaxAttempts() {
+				urlSaverMock.On("SaveURL", generated, mock.Anything).Return(nil).Once()
+			}
+
+			router := gin.New()
+			router.Use(withPrincipal)
+			router.POST("/api/save", save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, aliases, normalizer, newDestinationChecker(t), newURLGuard(t), &config.Config{}))
+
+			body, err := json.Marshal(save.Request{URL: "https://example.com"})
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/api/save", bytes.NewBuffer(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			var res save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+			require.Equal(t, tc.respError, res.Error)
+			if tc.respError == "" {
+				require.NotEmpty(t, res.Alias)
+			}
+		})
+	}
+}
+
+func TestSaveHandler_Dedupe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	normalizer := urlnorm.New(urlnorm.Options{})
+
+	cfg := &config.Config{}
+	cfg.Alias.Dedupe = true
+
+	cases := []struct {
+		name      string
+		request   save.Request
+		lookup    bool
+		existing  string
+		lookupErr error
+		save      bool
+		respError string
+		status    int
+		reused    bool
+	}{
+		{
+			name:     "Existing alias reused",
+			request:  save.Request{URL: "https://Example.com:443"},
+			lookup:   true,
+			existing: "existing",
+			status:   http.StatusOK,
+			reused:   true,
+		},
+		{
+			name:      "No existing alias",
+			request:   save.Request{URL: "https://example.com"},
+			lookup:    true,
+			lookupErr: storage.ErrURLNotFound,
+			save:      true,
+			status:    http.StatusOK,
+		},
+		{
+			name:     "Different redirect status",
+			request:  save.Request{URL: "https://example.com", RedirectStatus: http.StatusMovedPermanently},
+			lookup:   true,
+			existing: "existing",
+			save:     true,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "Different passthrough",
+			request:  save.Request{URL: "https://example.com", ForwardQuery: true},
+			lookup:   true,
+			existing: "existing",
+			save:     true,
+			status:   http.StatusOK,
+		},
+		{
+			name:    "Custom alias is never deduplicated",
+			request: save.Request{URL: "https://example.com", Alias: "custom"},
+			save:    true,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "Protected link is never deduplicated",
+			request: save.Request{URL: "https://example.com", Password: "secret"},
+			save:    true,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "Expiring link is never deduplicated",
+			request: save.Request{URL: "https://example.com", TTL: "1h"},
+			save:    true,
+			status:  http.StatusOK,
+		},
+		{
+			name:      "Lookup error",
+			request:   save.Request{URL: "https://example.com"},
+			lookup:    true,
+			lookupErr: errors.New("internal error"),
+			respError: "failed to add url",
+			status:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			urlSaverMock := mocks.NewURLSaver(t)
+
+			if tc.lookup {
+				urlSaverMock.On("FindGeneratedLink", int64(5), "https://example.com/").
+					Return(storage.Link{Alias: tc.existing}, tc.lookupErr).Once()
+			}
+
+			if tc.save {
+				urlSaverMock.On("SaveURL", mock.MatchedBy(func(link storage.Link) bool {
+					return link.Generated == (tc.request.Alias == "")
+				}), mock.Anything).Return(nil).Once()
+			}
+
+			aliases := alias.New(slogdiscard.NewDiscardLogger(), alias.Options{Length: 8, MaxAttempts: 1})
+
+			router := gin.New()
+			router.Use(withPrincipal)
+			router.POST("/api/save", save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, aliases, normalizer, newDestinationChecker(t), newURLGuard(t), cfg))
+
+			body, err := json.Marshal(tc.request)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/api/save", bytes.NewBuffer(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			var res save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+			require.Equal(t, tc.respError, res.Error)
+			require.Equal(t, tc.reused, res.Reused)
+			if tc.reused {
+				require.Equal(t, tc.existing, res.Alias)
+			}
+		})
+	}
+}
+
+// TestSaveHandler_DedupeDropParams keeps links whose stored URLs differ in
+// dropped parameters from being reused, as visitors are sent to the stored URL.
+func TestSaveHandler_DedupeDropParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{}
+	cfg.Alias.Dedupe = true
+	cfg.Normalize.DropParams = []string{"utm_*"}
+
+	normalizer := urlnorm.New(urlnorm.Options{DropParams: cfg.Normalize.DropParams})
+
+	cases := []struct {
+		name        string
+		url         string
+		existingURL string
+		reused      bool
+	}{
+		{
+			name:        "Same stored URL",
+			url:         "https://example.com/?utm_source=mail",
+			existingURL: "https://example.com/?utm_source=mail",
+			reused:      true,
+		},
+		{
+			name:        "Stored URL with other tracking parameters",
+			url:         "https://example.com/",
+			existingURL: "https://example.com/?utm_source=mail",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			urlSaverMock := mocks.NewURLSaver(t)
+
+			urlSaverMock.On("FindGeneratedLink", int64(5), "https://example.com/").
+				Return(storage.Link{Alias: "existing", URL: tc.existingURL}, nil).Once()
+
+			if !tc.reused {
+				urlSaverMock.On("SaveURL", mock.MatchedBy(func(link storage.Link) bool {
+					return link.URL == tc.url
+				}), mock.Anything).Return(nil).Once()
+			}
+
+			aliases := alias.New(slogdiscard.NewDiscardLogger(), alias.Options{Length: 8, MaxAttempts: 1})
+
+			router := gin.New()
+			router.Use(withPrincipal)
+			router.POST("/api/save", save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, aliases, normalizer, newDestinationChecker(t), newURLGuard(t), cfg))
+
+			body, err := json.Marshal(save.Request{URL: tc.url})
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/api/save", bytes.NewBuffer(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var res save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			require.Equal(t, tc.reused, res.Reused)
+		})
+	}
+}
+
+// newDestinationChecker blocks phish.example and allows every other host.
+func newDestinationChecker(t *testing.T) *mocks.DestinationChecker {
+	checker := mocks.NewDestinationChecker(t)
+	checker.On("Check", "phish.example").
+		Return(&domainpolicy.Violation{Host: "phish.example", Rule: "phish.example"}).Maybe()
+	checker.On("Check", mock.Anything).Return(nil).Maybe()
+
+	return checker
+}
+
+// newURLGuard rejects http://127.0.0.1:8080/, fails to check
+// https://slow.example/ and allows every other URL.
+func newURLGuard(t *testing.T) *mocks.URLGuard {
+	guard := mocks.NewURLGuard(t)
+	guard.On("Check", mock.Anything, "http://127.0.0.1:8080/").
+		Return(&ssrf.Violation{Host: "127.0.0.1", Reason: ssrf.ReasonAddress, Detail: "127.0.0.1"}).Maybe()
+	guard.On("Check", mock.Anything, "https://slow.example/").
+		Return(errors.New("lookup slow.example: i/o timeout")).Maybe()
+	guard.On("Check", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	return guard
+}
+
+func passwordMatches(hash, password string) bool {
+	if password == "" {
+		return hash == ""
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func withPrincipal(c *gin.Context) {
+	auth.SetPrincipal(c, auth.Principal{UserID: 5, Name: "pedro"})
+}
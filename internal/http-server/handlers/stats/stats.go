@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortener/internal/http-server/middleware/auth"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDays  = 30
+	maxDays      = 365
+	topReferrers = 10
+)
+
+type Response struct {
+	resp.Response
+	Alias        string           `json:"alias"`
+	TotalClicks  int64            `json:"total_clicks"`
+	ClicksPerDay []DailyClicks    `json:"clicks_per_day"`
+	TopReferrers []ReferrerClicks `json:"top_referrers"`
+}
+
+type DailyClicks struct {
+	Date   string `json:"date"`
+	Clicks int64  `json:"clicks"`
+}
+
+type ReferrerClicks struct {
+	Referrer string `json:"referrer"`
+	Clicks   int64  `json:"clicks"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=StatsGetter
+type StatsGetter interface {
+	GetStats(alias string, ownerID int64, since time.Time, topReferrers int) (storage.Stats, error)
+}
+
+func New(log *slog.Logger, statsGetter StatsGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const op = "handlers.url.stats.New"
+
+		alias := c.Param("alias")
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+			slog.String("alias", alias),
+		)
+
+		days := defaultDays
+		if rawDays := c.Query("days"); rawDays != "" {
+			var err error
+
+			days, err = strconv.Atoi(rawDays)
+			if err != nil || days < 1 || days > maxDays {
+				log.Info("invalid days parameter", slog.String("days", rawDays))
+				c.JSON(http.StatusBadRequest, resp.Error("days must be between 1 and 365"))
+				return
+			}
+		}
+
+		since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+
+		stats, err := statsGetter.GetStats(alias, auth.FromContext(c).OwnerScope(), since, topReferrers)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("alias not found")
+			c.JSON(http.StatusNotFound, resp.Error("alias not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get stats", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to get stats"))
+			return
+		}
+
+		res := Response{
+			Response:     resp.OK(),
+			Alias:        alias,
+			TotalClicks:  stats.TotalClicks,
+			ClicksPerDay: make([]DailyClicks, 0, len(stats.ClicksPerDay)),
+			TopReferrers: make([]ReferrerClicks, 0, len(stats.TopReferrers)),
+		}
+
+		for _, daily := range stats.ClicksPerDay {
+			res.ClicksPerDay = append(res.ClicksPerDay, DailyClicks{Date: daily.Date, Clicks: daily.Clicks})
+		}
+
+		for _, ref := range stats.TopReferrers {
+			res.TopReferrers = append(res.TopReferrers, ReferrerClicks{Referrer: ref.Referrer, Clicks: ref.Clicks})
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,121 @@
+package stats_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/http-server/handlers/stats"
+	"url-shortener/internal/http-server/handlers/stats/mocks"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name      string
+		alias     string
+		query     string
+		stats     storage.Stats
+		respError string
+		respBody  string
+		mockError error
+		status    int
+		skipGet   bool
+		principal auth.Principal
+		ownerID   int64
+	}{
+		{
+			name:  "Success",
+			alias: "test_alias",
+			stats: storage.Stats{
+				TotalClicks:  3,
+				ClicksPerDay: []storage.DailyClicks{{Date: "2024-05-01", Clicks: 3}},
+				TopReferrers: []storage.ReferrerClicks{{Referrer: "https://a.com", Clicks: 2}},
+			},
+			respBody: `"total_clicks":3,"clicks_per_day":[{"date":"2024-05-01","clicks":3}],"top_referrers":[{"referrer":"https://a.com","clicks":2}]`,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "No clicks",
+			alias:    "test_alias",
+			query:    "?days=7",
+			respBody: `"total_clicks":0,"clicks_per_day":[],"top_referrers":[]`,
+			status:   http.StatusOK,
+		},
+		{
+			name:      "Invalid days",
+			alias:     "test_alias",
+			query:     "?days=0",
+			respError: "days must be between 1 and 365",
+			status:    http.StatusBadRequest,
+			skipGet:   true,
+		},
+		{
+			name:      "Alias not found",
+			alias:     "unknown",
+			respError: "alias not found",
+			mockError: storage.ErrURLNotFound,
+			status:    http.StatusNotFound,
+		},
+		{
+			name:      "Viewer scoped to its own links",
+			alias:     "other_alias",
+			respError: "alias not found",
+			mockError: storage.ErrURLNotFound,
+			status:    http.StatusNotFound,
+			principal: auth.Principal{UserID: 9, Name: "viewer", Role: rbac.RoleViewer},
+			ownerID:   9,
+		},
+		{
+			name:      "Internal error",
+			alias:     "test_alias",
+			respError: "failed to get stats",
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			statsGetterMock := mocks.NewStatsGetter(t)
+
+			if !tc.skipGet {
+				statsGetterMock.On("GetStats", tc.alias, tc.ownerID, mock.AnythingOfType("time.Time"), 10).
+					Return(tc.stats, tc.mockError).Once()
+			}
+
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				auth.SetPrincipal(c, tc.principal)
+			})
+			router.GET("/api/link/:alias/stats", stats.New(slogdiscard.NewDiscardLogger(), statsGetterMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/api/link/"+tc.alias+"/stats"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			if tc.respError != "" {
+				require.Contains(t, rr.Body.String(), tc.respError)
+			} else {
+				require.Contains(t, rr.Body.String(), tc.respBody)
+			}
+		})
+	}
+}
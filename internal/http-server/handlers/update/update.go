@@ -0,0 +1,168 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"url-shortener/internal/audit"
+	"url-shortener/internal/domainpolicy"
+	"url-shortener/internal/http-server/middleware/auth"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/expiry"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/ssrf"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Request lists the mutable fields of a link, omitted fields keep their value.
+type Request struct {
+	URL       *string    `json:"url,omitempty" validate:"omitempty,url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" validate:"omitempty,excluded_with=TTL NoExpiry"`
+	TTL       string     `json:"ttl,omitempty" validate:"omitempty,excluded_with=ExpiresAt NoExpiry"`
+	NoExpiry  bool       `json:"no_expiry,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=URLUpdater
+type URLUpdater interface {
+	UpdateURL(alias string, ownerID int64, update storage.LinkUpdate, audit ...storage.AuditEntry) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=DestinationChecker
+type DestinationChecker interface {
+	Check(host string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=URLGuard
+type URLGuard interface {
+	Check(ctx context.Context, rawURL string) error
+}
+
+func New(
+	log *slog.Logger,
+	urlUpdater URLUpdater,
+	normalizer *urlnorm.Normalizer,
+	checker DestinationChecker,
+	guard URLGuard,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const op = "handlers.url.update.New"
+
+		alias := c.Param("alias")
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+			slog.String("alias", alias),
+		)
+
+		var req Request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error("failed to decode request"))
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		validate := validator.New()
+		if err := validate.Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.ValidationError(validateErr))
+			return
+		}
+
+		expiresAt, err := expiry.Resolve(req.ExpiresAt, req.TTL, time.Now())
+		if err != nil {
+			log.Error("invalid expiry", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		update := storage.LinkUpdate{
+			URL:         req.URL,
+			ExpiresAt:   expiresAt,
+			ClearExpiry: req.NoExpiry,
+		}
+
+		if req.URL != nil {
+			canonical, err := normalizer.Normalize(*req.URL)
+			if err != nil {
+				log.Error("failed to normalize url", sl.Err(err))
+				c.JSON(http.StatusBadRequest, resp.Error("field URL is not a valid URL"))
+				return
+			}
+
+			var unsafe *ssrf.Violation
+			err = guard.Check(c.Request.Context(), canonical)
+			if errors.As(err, &unsafe) {
+				log.Info("unsafe destination rejected", slog.String("reason", unsafe.Reason))
+				c.JSON(http.StatusUnprocessableEntity, resp.Violation(unsafe.Error(), unsafe.Reason))
+				return
+			}
+			if err != nil {
+				log.Error("failed to check destination", sl.Err(err))
+				c.JSON(http.StatusServiceUnavailable, resp.Error("failed to check destination"))
+				return
+			}
+
+			var violation *domainpolicy.Violation
+			err = checker.Check(storage.HostOf(canonical))
+			if errors.As(err, &violation) {
+				log.Info("destination rejected by domain policy", slog.String("rule", violation.Rule))
+				c.JSON(http.StatusUnprocessableEntity, resp.Violation(violation.Error(), violation.Rule))
+				return
+			}
+			if err != nil {
+				log.Error("failed to check domain policy", sl.Err(err))
+				c.JSON(http.StatusInternalServerError, resp.Error("failed to update url"))
+				return
+			}
+
+			update.CanonicalURL = &canonical
+		}
+
+		if update.Empty() {
+			log.Info("nothing to update")
+			c.JSON(http.StatusBadRequest, resp.Error("nothing to update"))
+			return
+		}
+
+		// The storage fills in the link before and after the update, read in
+		// the same transaction.
+		entry, err := audit.Entry(c, storage.ActionLinkUpdate, alias, nil, nil)
+		if err != nil {
+			log.Error("failed to build audit entry", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to update url"))
+			return
+		}
+
+		err = urlUpdater.UpdateURL(alias, auth.FromContext(c).OwnerScope(), update, entry)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("alias not found")
+			c.JSON(http.StatusNotFound, resp.Error("alias not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrNotOwner) {
+			log.Info("alias owned by another user")
+			c.JSON(http.StatusForbidden, resp.Error("alias owned by another user"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to update url", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to update url"))
+			return
+		}
+
+		log.Info("url updated")
+
+		c.JSON(http.StatusOK, resp.OK())
+	}
+}
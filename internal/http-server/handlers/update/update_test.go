@@ -0,0 +1,253 @@
+package update_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/domainpolicy"
+	"url-shortener/internal/http-server/handlers/update"
+	"url-shortener/internal/http-server/handlers/update/mocks"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/ssrf"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	normalizer := urlnorm.New(urlnorm.Options{DropParams: []string{"utm_*"}})
+
+	cases := []struct {
+		name       string
+		alias      string
+		body       string
+		respError  string
+		mockError  error
+		status     int
+		skipUpdate bool
+		role       rbac.Role
+		match      func(update storage.LinkUpdate) bool
+	}{
+		{
+			name:   "Success",
+			alias:  "test_alias",
+			body:   `{"url": "https://example.com"}`,
+			status: http.StatusOK,
+			match: func(u storage.LinkUpdate) bool {
+				return u.URL != nil && *u.URL == "https://example.com" && u.ExpiresAt == nil && !u.ClearExpiry
+			},
+		},
+		{
+			name:   "Canonical URL",
+			alias:  "test_alias",
+			body:   `{"url": "https://Example.com:443/a/../b?utm_source=mail"}`,
+			status: http.StatusOK,
+			match: func(u storage.LinkUpdate) bool {
+				return *u.URL == "https://Example.com:443/a/../b?utm_source=mail" &&
+					u.CanonicalURL != nil && *u.CanonicalURL == "https://example.com/b"
+			},
+		},
+		{
+			name:   "Extend expiry",
+			alias:  "test_alias",
+			body:   `{"ttl": "48h"}`,
+			status: http.StatusOK,
+			match: func(u storage.LinkUpdate) bool {
+				return u.URL == nil && u.CanonicalURL == nil && u.ExpiresAt != nil
+			},
+		},
+		{
+			name:   "Remove expiry",
+			alias:  "test_alias",
+			body:   `{"no_expiry": true}`,
+			status: http.StatusOK,
+			match: func(u storage.LinkUpdate) bool {
+				return u.URL == nil && u.ClearExpiry
+			},
+		},
+		{
+			name:       "Invalid URL",
+			alias:      "test_alias",
+			body:       `{"url": "invalid-url"}`,
+			respError:  "field URL is not a valid URL",
+			status:     http.StatusBadRequest,
+			skipUpdate: true,
+		},
+		{
+			name:       "Private destination",
+			alias:      "test_alias",
+			body:       `{"url": "http://192.168.0.1/admin"}`,
+			respError:  `"rule":"private_address"`,
+			status:     http.StatusUnprocessableEntity,
+			skipUpdate: true,
+		},
+		{
+			name:       "Unchecked destination",
+			alias:      "test_alias",
+			body:       `{"url": "https://slow.example"}`,
+			respError:  "failed to check destination",
+			status:     http.StatusServiceUnavailable,
+			skipUpdate: true,
+		},
+		{
+			name:       "Blocked domain",
+			alias:      "test_alias",
+			body:       `{"url": "https://phish.example/login"}`,
+			respError:  `"rule":"phish.example"`,
+			status:     http.StatusUnprocessableEntity,
+			skipUpdate: true,
+		},
+		{
+			name:       "Conflicting expiry",
+			alias:      "test_alias",
+			body:       `{"ttl": "1h", "no_expiry": true}`,
+			respError:  "field TTL cannot be used together with ExpiresAt or NoExpiry",
+			status:     http.StatusBadRequest,
+			skipUpdate: true,
+		},
+		{
+			name:       "Nothing to update",
+			alias:      "test_alias",
+			body:       `{}`,
+			respError:  "nothing to update",
+			status:     http.StatusBadRequest,
+			skipUpdate: true,
+		},
+		{
+			name:      "Alias not found",
+			alias:     "unknown",
+			body:      `{"url": "https://example.com"}`,
+			respError: "alias not found",
+			mockError: storage.ErrURLNotFound,
+			status:    http.StatusNotFound,
+		},
+		{
+			name:      "Owned by another user",
+			alias:     "test_alias",
+			body:      `{"url": "https://example.com"}`,
+			respError: "alias owned by another user",
+			mockError: storage.ErrNotOwner,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:   "Admin updates any alias",
+			alias:  "test_alias",
+			body:   `{"url": "https://example.com"}`,
+			status: http.StatusOK,
+			role:   rbac.RoleAdmin,
+		},
+		{
+			name:      "Internal error",
+			alias:     "test_alias",
+			body:      `{"url": "https://example.com"}`,
+			respError: "failed to update url",
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			urlUpdaterMock := mocks.NewURLUpdater(t)
+
+			if !tc.skipUpdate {
+				match := tc.match
+				if match == nil {
+					match = func(storage.LinkUpdate) bool { return true }
+				}
+
+				// Admins are not restricted to their own links.
+				ownerID := int64(5)
+				if tc.role == rbac.RoleAdmin {
+					ownerID = 0
+				}
+
+				urlUpdaterMock.On("UpdateURL", tc.alias, ownerID, mock.MatchedBy(match), mock.Anything).
+					Return(tc.mockError).Once()
+			}
+
+			guardMock := mocks.NewURLGuard(t)
+			guardMock.On("Check", mock.Anything, "http://192.168.0.1/admin").
+				Return(&ssrf.Violation{Host: "192.168.0.1", Reason: ssrf.ReasonAddress, Detail: "192.168.0.1"}).Maybe()
+			guardMock.On("Check", mock.Anything, "https://slow.example/").
+				Return(errors.New("lookup slow.example: i/o timeout")).Maybe()
+			guardMock.On("Check", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			checkerMock := mocks.NewDestinationChecker(t)
+			checkerMock.On("Check", "phish.example").
+				Return(&domainpolicy.Violation{Host: "phish.example", Rule: "phish.example"}).Maybe()
+			checkerMock.On("Check", mock.Anything).Return(nil).Maybe()
+
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				auth.SetPrincipal(c, auth.Principal{UserID: 5, Name: "pedro", Role: tc.role})
+			})
+			router.PATCH("/api/link/:alias", update.New(slogdiscard.NewDiscardLogger(), urlUpdaterMock, normalizer, checkerMock, guardMock))
+
+			req, err := http.NewRequest(http.MethodPatch, "/api/link/"+tc.alias, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			if tc.respError != "" {
+				require.Contains(t, rr.Body.String(), tc.respError)
+			} else {
+				require.Contains(t, rr.Body.String(), `"status":"OK"`)
+			}
+		})
+	}
+}
+
+func TestUpdateHandler_Audit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The entry is passed to the update, whose storage fills in the link
+	// before and after the change in the same transaction.
+	entry := storage.AuditEntry{
+		Actor:   "pedro",
+		ActorID: 5,
+		Action:  storage.ActionLinkUpdate,
+		Alias:   "test_alias",
+	}
+
+	urlUpdaterMock := mocks.NewURLUpdater(t)
+	urlUpdaterMock.On("UpdateURL", "test_alias", int64(5), mock.Anything, entry).Return(nil).Once()
+
+	guardMock := mocks.NewURLGuard(t)
+	guardMock.On("Check", mock.Anything, mock.Anything).Return(nil)
+
+	checkerMock := mocks.NewDestinationChecker(t)
+	checkerMock.On("Check", "new.example.com").Return(nil)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		auth.SetPrincipal(c, auth.Principal{UserID: 5, Name: "pedro", Role: rbac.RoleEditor})
+	})
+	router.PATCH("/api/link/:alias", update.New(slogdiscard.NewDiscardLogger(), urlUpdaterMock, urlnorm.New(urlnorm.Options{}), checkerMock, guardMock))
+
+	req, err := http.NewRequest(http.MethodPatch, "/api/link/test_alias", bytes.NewBufferString(`{"url": "https://new.example.com", "no_expiry": true}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
@@ -0,0 +1,91 @@
+package users
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"url-shortener/internal/audit"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type CreateRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+	// Role decides what the user may do, rbac.RoleEditor when omitted.
+	Role string `json:"role,omitempty" validate:"omitempty,oneof=viewer editor admin"`
+}
+
+type CreateResponse struct {
+	resp.Response
+	ID int64 `json:"id,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=UserCreator
+type UserCreator interface {
+	CreateUser(user storage.User, audit ...storage.AuditEntry) (int64, error)
+}
+
+func NewCreate(log *slog.Logger, userCreator UserCreator) gin.HandlerFunc {
+	validate := validator.New()
+
+	return func(c *gin.Context) {
+		const op = "handlers.users.NewCreate"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+		)
+
+		var req CreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.Error("failed to decode request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+			c.JSON(http.StatusBadRequest, resp.ValidationError(validateErr))
+			return
+		}
+
+		role := rbac.RoleEditor
+		if req.Role != "" {
+			role = rbac.Role(req.Role)
+		}
+
+		// The storage records the id of the new user as the target of the entry.
+		entry, err := audit.Entry(c, storage.ActionUserCreate, "", nil, gin.H{"name": req.Name, "role": role})
+		if err != nil {
+			log.Error("failed to build audit entry", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to create user"))
+			return
+		}
+
+		id, err := userCreator.CreateUser(storage.User{Name: req.Name, Role: string(role)}, entry)
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Info("user already exists", slog.String("name", req.Name))
+			c.JSON(http.StatusConflict, resp.Error("user already exists"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to create user", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to create user"))
+			return
+		}
+
+		log.Info("user created", slog.Int64("id", id), slog.String("name", req.Name), slog.String("role", string(role)))
+
+		c.JSON(http.StatusCreated, CreateResponse{
+			Response: resp.OK(),
+			ID:       id,
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package users_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/http-server/handlers/users"
+	"url-shortener/internal/http-server/handlers/users/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name      string
+		request   users.CreateRequest
+		role      string
+		mockError error
+		skipSave  bool
+		status    int
+		respError string
+	}{
+		{
+			name:    "Success",
+			request: users.CreateRequest{Name: "maria"},
+			role:    "editor",
+			status:  http.StatusCreated,
+		},
+		{
+			name:    "Admin",
+			request: users.CreateRequest{Name: "root", Role: "admin"},
+			role:    "admin",
+			status:  http.StatusCreated,
+		},
+		{
+			name:    "Viewer",
+			request: users.CreateRequest{Name: "dashboard", Role: "viewer"},
+			role:    "viewer",
+			status:  http.StatusCreated,
+		},
+		{
+			name:      "Unknown role",
+			request:   users.CreateRequest{Name: "root", Role: "root"},
+			skipSave:  true,
+			status:    http.StatusBadRequest,
+			respError: "field Role must be one of: viewer, editor, admin",
+		},
+		{
+			name:      "Missing name",
+			request:   users.CreateRequest{},
+			skipSave:  true,
+			status:    http.StatusBadRequest,
+			respError: "field Name is a required field",
+		},
+		{
+			name:      "User exists",
+			request:   users.CreateRequest{Name: "maria"},
+			role:      "editor",
+			mockError: storage.ErrUserExists,
+			status:    http.StatusConflict,
+			respError: "user already exists",
+		},
+		{
+			name:      "Internal error",
+			request:   users.CreateRequest{Name: "maria"},
+			role:      "editor",
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+			respError: "failed to create user",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			userCreatorMock := mocks.NewUserCreator(t)
+			if !tc.skipSave {
+				// The storage sets the id of the new user as the target.
+				entry := storage.AuditEntry{
+					Action: storage.ActionUserCreate,
+					After:  `{"name":"` + tc.request.Name + `","role":"` + tc.role + `"}`,
+				}
+
+				userCreatorMock.On("CreateUser", storage.User{Name: tc.request.Name, Role: tc.role}, entry).
+					Return(int64(4), tc.mockError).Once()
+			}
+
+			router := gin.New()
+			router.POST("/users", users.NewCreate(slogdiscard.NewDiscardLogger(), userCreatorMock))
+
+			body, err := json.Marshal(tc.request)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			var res users.CreateResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			require.Equal(t, tc.respError, res.Error)
+
+			if tc.status == http.StatusCreated {
+				require.Equal(t, int64(4), res.ID)
+			}
+		})
+	}
+}
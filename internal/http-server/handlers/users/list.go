@@ -0,0 +1,50 @@
+package users
+
+import (
+	"log/slog"
+	"net/http"
+
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ListResponse struct {
+	resp.Response
+	Users []User `json:"users"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=UserLister
+type UserLister interface {
+	ListUsers() ([]storage.User, error)
+}
+
+func NewList(log *slog.Logger, userLister UserLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const op = "handlers.users.NewList"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+		)
+
+		stored, err := userLister.ListUsers()
+		if err != nil {
+			log.Error("failed to list users", sl.Err(err))
+			c.JSON(http.StatusInternalServerError, resp.Error("failed to list users"))
+			return
+		}
+
+		users := make([]User, 0, len(stored))
+		for _, user := range stored {
+			users = append(users, fromStorage(user))
+		}
+
+		c.JSON(http.StatusOK, ListResponse{
+			Response: resp.OK(),
+			Users:    users,
+		})
+	}
+}
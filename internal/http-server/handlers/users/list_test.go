@@ -0,0 +1,79 @@
+package users_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/users"
+	"url-shortener/internal/http-server/handlers/users/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		users     []storage.User
+		mockError error
+		status    int
+		want      []users.User
+		respError string
+	}{
+		{
+			name: "Success",
+			users: []storage.User{
+				{ID: 1, Name: "pedro", Role: "admin", CreatedAt: createdAt},
+				{ID: 2, Name: "maria", CreatedAt: createdAt},
+			},
+			status: http.StatusOK,
+			want: []users.User{
+				{ID: 1, Name: "pedro", Role: "admin", CreatedAt: createdAt},
+				{ID: 2, Name: "maria", CreatedAt: createdAt},
+			},
+		},
+		{
+			name:      "Internal error",
+			mockError: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+			respError: "failed to list users",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			userListerMock := mocks.NewUserLister(t)
+			userListerMock.On("ListUsers").Return(tc.users, tc.mockError).Once()
+
+			router := gin.New()
+			router.GET("/users", users.NewList(slogdiscard.NewDiscardLogger(), userListerMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/users", nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			var res users.ListResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			require.Equal(t, tc.respError, res.Error)
+			require.Equal(t, tc.want, res.Users)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package users
+
+import (
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func fromStorage(user storage.User) User {
+	return User{
+		ID:        user.ID,
+		Name:      user.Name,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+	}
+}
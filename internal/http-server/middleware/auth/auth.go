@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/oidc"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// touchInterval limits how often the last use of a key is written, so busy
+// clients do not cause a write per request.
+const touchInterval = time.Minute
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=CredentialStore
+type CredentialStore interface {
+	GetAPIKey(hash string) (storage.APIKey, error)
+	TouchAPIKey(id int64, at time.Time) error
+	GetUser(id int64) (storage.User, error)
+	GetUserByName(name string) (storage.User, error)
+	GetUserBySubject(issuer, subject string) (storage.User, error)
+	CreateUser(user storage.User, audit ...storage.AuditEntry) (int64, error)
+}
+
+// errLocalUser is returned by ssoUser for subjects named like a local user.
+var errLocalUser = errors.New("subject is the name of a local user")
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=TokenVerifier
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (oidc.Identity, error)
+}
+
+// New authenticates requests with an API key or, when tokens is not nil, an
+// SSO issued JWT sent as a bearer token, or otherwise with basic auth against
+// accounts, and stores the user the credentials belong to as the Principal.
+// The basic auth accounts come from the config and always act as admins, keys
+// act with the role of their user, capped by the role of the key, and JWTs
+// with the role mapped from their groups.
+func New(log *slog.Logger, store CredentialStore, accounts gin.Accounts, tokens TokenVerifier) gin.HandlerFunc {
+	basicAuth := gin.BasicAuth(accounts)
+
+	return func(c *gin.Context) {
+		const op = "middleware.auth.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+		)
+
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			basicAuth(c)
+			if c.IsAborted() {
+				return
+			}
+
+			user, err := store.GetUserByName(c.GetString(gin.AuthUserKey))
+			if err != nil {
+				log.Error("failed to look up basic auth user", sl.Err(err))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, resp.Error("internal error"))
+				return
+			}
+
+			SetPrincipal(c, Principal{UserID: user.ID, Name: user.Name, Role: rbac.RoleAdmin})
+			return
+		}
+
+		if tokens != nil && !strings.HasPrefix(token, apikey.TokenPrefix) {
+			authenticateJWT(c, log, store, tokens, token)
+			return
+		}
+
+		now := time.Now()
+
+		key, err := lookup(store, token)
+		if errors.Is(err, storage.ErrAPIKeyNotFound) || err == nil && !key.Active(now) {
+			log.Info("invalid api key", slog.String("prefix", apikey.Prefix(token)))
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, resp.Error("invalid api key"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to look up api key", sl.Err(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, resp.Error("internal error"))
+			return
+		}
+
+		if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= touchInterval {
+			if err := store.TouchAPIKey(key.ID, now); err != nil {
+				log.Error("failed to record api key use", slog.Int64("key_id", key.ID), sl.Err(err))
+			}
+		}
+
+		user, err := store.GetUser(key.UserID)
+		if err != nil {
+			log.Error("failed to look up api key user", slog.Int64("key_id", key.ID), sl.Err(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, resp.Error("internal error"))
+			return
+		}
+
+		role := rbac.Role(user.Role).Cap(rbac.Role(key.Role))
+
+		SetPrincipal(c, Principal{UserID: user.ID, Name: user.Name, Role: role})
+	}
+}
+
+// authenticateJWT verifies an SSO token and signs its subject in as the user of
+// the same name, which is created on first sign-in.
+func authenticateJWT(c *gin.Context, log *slog.Logger, store CredentialStore, tokens TokenVerifier, token string) {
+	identity, err := tokens.Verify(c.Request.Context(), token)
+	if errors.Is(err, oidc.ErrInvalidToken) {
+		log.Info("invalid token", sl.Err(err))
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, resp.Error("invalid token"))
+		return
+	}
+	if err != nil {
+		log.Error("failed to verify token", sl.Err(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, resp.Error("internal error"))
+		return
+	}
+
+	// Tokens without a role are rejected by every route, there is no point in
+	// creating users for them.
+	if identity.Role == "" {
+		SetPrincipal(c, Principal{Name: identity.Subject})
+		return
+	}
+
+	user, err := ssoUser(c, store, identity)
+	if errors.Is(err, errLocalUser) {
+		log.Warn("token subject is the name of a local user", slog.String("subject", identity.Subject))
+		c.AbortWithStatusJSON(http.StatusForbidden, resp.Error("token subject belongs to a local user"))
+		return
+	}
+	if err != nil {
+		log.Error("failed to look up token user", slog.String("subject", identity.Subject), sl.Err(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, resp.Error("internal error"))
+		return
+	}
+
+	SetPrincipal(c, Principal{UserID: user.ID, Name: user.Name, Role: identity.Role})
+}
+
+// ssoUser returns the SSO user of the issuer and subject of identity, creating
+// it with the role of the token when it signs in for the first time. The role
+// is only recorded there, requests always act with the role of the token.
+// Tokens are never mapped onto local users, errLocalUser is returned when the
+// subject is the name of one. Created users are recorded in the audit log on
+// behalf of the system.
+func ssoUser(c *gin.Context, store CredentialStore, identity oidc.Identity) (storage.User, error) {
+	user, err := store.GetUserBySubject(identity.Issuer, identity.Subject)
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return user, err
+	}
+
+	user = storage.User{
+		Name:    identity.Subject,
+		Role:    string(identity.Role),
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+	}
+
+	after, err := json.Marshal(gin.H{"name": user.Name, "role": user.Role, "issuer": user.Issuer, "subject": user.Subject})
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	// Built by hand like audit.Entry, as the audit package depends on this one.
+	entry := storage.AuditEntry{
+		Actor:     storage.SystemActor,
+		Action:    storage.ActionUserCreate,
+		After:     string(after),
+		RequestID: c.GetString("request_id"),
+		ClientIP:  c.ClientIP(),
+	}
+
+	id, err := store.CreateUser(user, entry)
+	if errors.Is(err, storage.ErrUserExists) {
+		// Either created by a concurrent request of the same user, or the
+		// name belongs to a local user the token must not act as.
+		user, err := store.GetUserBySubject(identity.Issuer, identity.Subject)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return storage.User{}, errLocalUser
+		}
+		return user, err
+	}
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	user.ID = id
+
+	return user, nil
+}
+
+func lookup(store CredentialStore, token string) (storage.APIKey, error) {
+	if !apikey.Valid(token) {
+		return storage.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+
+	return store.GetAPIKey(apikey.Hash(token))
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
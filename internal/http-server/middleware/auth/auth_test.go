@@ -0,0 +1,372 @@
+package auth_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/http-server/middleware/auth/mocks"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/oidc"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	token := "usk_" + strings.Repeat("a", 40)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	recent := time.Now().Add(-time.Second)
+
+	cases := []struct {
+		name      string
+		header    string
+		basicUser string
+		basicPass string
+		key       *storage.APIKey
+		lookupErr error
+		touch     bool
+		status    int
+		user      string
+		role      rbac.Role
+	}{
+		{
+			name:   "Valid key",
+			header: "Bearer " + token,
+			key:    &storage.APIKey{ID: 1, Name: "ci", UserID: 5},
+			touch:  true,
+			status: http.StatusOK,
+			user:   "billing",
+			role:   rbac.RoleEditor,
+		},
+		{
+			name:   "Scheme is case-insensitive",
+			header: "bearer " + token,
+			key:    &storage.APIKey{ID: 1, Name: "ci", UserID: 5, ExpiresAt: &future},
+			touch:  true,
+			status: http.StatusOK,
+			user:   "billing",
+			role:   rbac.RoleEditor,
+		},
+		{
+			name:   "Recently used key not touched",
+			header: "Bearer " + token,
+			key:    &storage.APIKey{ID: 1, Name: "ci", UserID: 5, LastUsedAt: &recent},
+			status: http.StatusOK,
+			user:   "billing",
+			role:   rbac.RoleEditor,
+		},
+		{
+			name:   "Stale last use touched",
+			header: "Bearer " + token,
+			key:    &storage.APIKey{ID: 1, Name: "ci", UserID: 5, LastUsedAt: &past},
+			touch:  true,
+			status: http.StatusOK,
+			user:   "billing",
+			role:   rbac.RoleEditor,
+		},
+		{
+			name:   "Key restricted to a lower role",
+			header: "Bearer " + token,
+			key:    &storage.APIKey{ID: 1, Name: "dashboard", UserID: 5, Role: "viewer"},
+			touch:  true,
+			status: http.StatusOK,
+			user:   "billing",
+			role:   rbac.RoleViewer,
+		},
+		{
+			name:   "Key role does not elevate its user",
+			header: "Bearer " + token,
+			key:    &storage.APIKey{ID: 1, Name: "ci", UserID: 5, Role: "admin"},
+			touch:  true,
+			status: http.StatusOK,
+			user:   "billing",
+			role:   rbac.RoleEditor,
+		},
+		{
+			name:   "Revoked key",
+			header: "Bearer " + token,
+			key:    &storage.APIKey{ID: 1, Name: "ci", UserID: 5, RevokedAt: &past},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "Expired key",
+			header: "Bearer " + token,
+			key:    &storage.APIKey{ID: 1, Name: "ci", UserID: 5, ExpiresAt: &past},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:      "Unknown key",
+			header:    "Bearer " + token,
+			lookupErr: storage.ErrAPIKeyNotFound,
+			status:    http.StatusUnauthorized,
+		},
+		{
+			name:   "Malformed key",
+			header: "Bearer nope",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:      "Lookup error",
+			header:    "Bearer " + token,
+			lookupErr: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+		},
+		{
+			name:      "Basic auth fallback",
+			basicUser: "pedro",
+			basicPass: "secret",
+			status:    http.StatusOK,
+			user:      "pedro",
+			role:      rbac.RoleAdmin,
+		},
+		{
+			name:      "Wrong basic auth password",
+			basicUser: "pedro",
+			basicPass: "wrong",
+			status:    http.StatusUnauthorized,
+		},
+		{
+			name:   "No credentials",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storeMock := mocks.NewCredentialStore(t)
+			if tc.key != nil || tc.lookupErr != nil {
+				storeMock.On("GetAPIKey", apikey.Hash(token)).Return(derefKey(tc.key), tc.lookupErr).Once()
+			}
+			if tc.touch {
+				storeMock.On("TouchAPIKey", tc.key.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+			}
+			if tc.status == http.StatusOK && tc.key != nil {
+				storeMock.On("GetUser", int64(5)).Return(storage.User{ID: 5, Name: "billing", Role: "editor"}, nil).Once()
+			}
+			if tc.status == http.StatusOK && tc.basicUser != "" {
+				storeMock.On("GetUserByName", "pedro").Return(storage.User{ID: 1, Name: "pedro"}, nil).Once()
+			}
+
+			router := gin.New()
+			router.Use(auth.New(slogdiscard.NewDiscardLogger(), storeMock, gin.Accounts{"pedro": "secret"}, nil))
+			var principal auth.Principal
+			router.GET("/", func(c *gin.Context) {
+				principal = auth.FromContext(c)
+				c.Status(http.StatusOK)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			require.NoError(t, err)
+
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			if tc.basicUser != "" {
+				req.SetBasicAuth(tc.basicUser, tc.basicPass)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+			require.Equal(t, tc.user, principal.Name)
+			require.Equal(t, tc.role, principal.Role)
+			if tc.status == http.StatusUnauthorized {
+				require.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAuth_JWT(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const jwt = "eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJtYXJpYSJ9.c2ln"
+	const issuer = "https://sso.example.com"
+	identity := oidc.Identity{Issuer: issuer, Subject: "maria", Groups: []string{"shortener-admins"}, Role: rbac.RoleAdmin}
+
+	cases := []struct {
+		name      string
+		header    string
+		identity  *oidc.Identity
+		verifyErr error
+		existing  *storage.User
+		lookupErr error
+		createErr error
+		localUser bool
+		status    int
+		principal auth.Principal
+	}{
+		{
+			name:      "Known user acts with the role of the token",
+			header:    "Bearer " + jwt,
+			existing:  &storage.User{ID: 7, Name: "maria", Role: "viewer"},
+			status:    http.StatusOK,
+			principal: auth.Principal{UserID: 7, Name: "maria", Role: rbac.RoleAdmin},
+		},
+		{
+			name:      "User created on first sign-in",
+			header:    "Bearer " + jwt,
+			status:    http.StatusOK,
+			principal: auth.Principal{UserID: 8, Name: "maria", Role: rbac.RoleAdmin},
+		},
+		{
+			name:      "User created concurrently",
+			header:    "Bearer " + jwt,
+			createErr: storage.ErrUserExists,
+			status:    http.StatusOK,
+			principal: auth.Principal{UserID: 7, Name: "maria", Role: rbac.RoleAdmin},
+		},
+		{
+			name:      "Subject named like a local user",
+			header:    "Bearer " + jwt,
+			createErr: storage.ErrUserExists,
+			localUser: true,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:      "No role is not signed in as a user",
+			header:    "Bearer " + jwt,
+			identity:  &oidc.Identity{Issuer: issuer, Subject: "maria", Groups: []string{"staff"}},
+			status:    http.StatusOK,
+			principal: auth.Principal{Name: "maria"},
+		},
+		{
+			name:      "Invalid token",
+			header:    "Bearer " + jwt,
+			verifyErr: fmt.Errorf("verify: %w: token is expired", oidc.ErrInvalidToken),
+			status:    http.StatusUnauthorized,
+		},
+		{
+			name:      "Verification error",
+			header:    "Bearer " + jwt,
+			verifyErr: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+		},
+		{
+			name:      "User lookup error",
+			header:    "Bearer " + jwt,
+			lookupErr: errors.New("internal error"),
+			status:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			verified := identity
+			if tc.identity != nil {
+				verified = *tc.identity
+			}
+
+			tokensMock := mocks.NewTokenVerifier(t)
+			tokensMock.On("Verify", mock.Anything, jwt).Return(verified, tc.verifyErr).Once()
+
+			storeMock := mocks.NewCredentialStore(t)
+			if tc.verifyErr == nil && verified.Role != "" {
+				switch {
+				case tc.lookupErr != nil:
+					storeMock.On("GetUserBySubject", issuer, "maria").Return(storage.User{}, tc.lookupErr).Once()
+				case tc.existing != nil:
+					storeMock.On("GetUserBySubject", issuer, "maria").Return(*tc.existing, nil).Once()
+				default:
+					storeMock.On("GetUserBySubject", issuer, "maria").Return(storage.User{}, storage.ErrUserNotFound).Once()
+					storeMock.On("CreateUser", storage.User{Name: "maria", Role: "admin", Issuer: issuer, Subject: "maria"}, mock.MatchedBy(func(entry storage.AuditEntry) bool {
+						return entry.Actor == "system" && entry.Action == "user.create" &&
+							entry.After == `{"issuer":"`+issuer+`","name":"maria","role":"admin","subject":"maria"}`
+					})).
+						Return(int64(8), tc.createErr).Once()
+					if tc.localUser {
+						storeMock.On("GetUserBySubject", issuer, "maria").Return(storage.User{}, storage.ErrUserNotFound).Once()
+					} else if tc.createErr != nil {
+						storeMock.On("GetUserBySubject", issuer, "maria").
+							Return(storage.User{ID: 7, Name: "maria", Role: "editor", Issuer: issuer, Subject: "maria"}, nil).Once()
+					}
+				}
+			}
+
+			router := gin.New()
+			router.Use(auth.New(slogdiscard.NewDiscardLogger(), storeMock, gin.Accounts{"pedro": "secret"}, tokensMock))
+			var principal auth.Principal
+			router.GET("/", func(c *gin.Context) {
+				principal = auth.FromContext(c)
+				c.Status(http.StatusOK)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", tc.header)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+			require.Equal(t, tc.principal, principal)
+			if tc.status == http.StatusUnauthorized {
+				require.Equal(t, `Bearer error="invalid_token"`, rr.Header().Get("WWW-Authenticate"))
+				require.Contains(t, rr.Body.String(), "invalid token")
+			}
+		})
+	}
+}
+
+// API keys keep working when SSO tokens are enabled.
+func TestAuth_APIKeyWithJWT(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	token := "usk_" + strings.Repeat("a", 40)
+
+	storeMock := mocks.NewCredentialStore(t)
+	storeMock.On("GetAPIKey", apikey.Hash(token)).Return(storage.APIKey{ID: 1, Name: "ci", UserID: 5}, nil).Once()
+	storeMock.On("TouchAPIKey", int64(1), mock.AnythingOfType("time.Time")).Return(nil).Once()
+	storeMock.On("GetUser", int64(5)).Return(storage.User{ID: 5, Name: "billing", Role: "editor"}, nil).Once()
+
+	router := gin.New()
+	router.Use(auth.New(slogdiscard.NewDiscardLogger(), storeMock, gin.Accounts{"pedro": "secret"}, mocks.NewTokenVerifier(t)))
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, auth.FromContext(c).Name)
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "billing", rr.Body.String())
+}
+
+func derefKey(key *storage.APIKey) storage.APIKey {
+	if key == nil {
+		return storage.APIKey{}
+	}
+	return *key
+}
+
+func TestPrincipal_OwnerScope(t *testing.T) {
+	require.Equal(t, int64(5), auth.Principal{UserID: 5}.OwnerScope())
+	require.Equal(t, int64(5), auth.Principal{UserID: 5, Role: rbac.RoleEditor}.OwnerScope())
+	require.Zero(t, auth.Principal{UserID: 1, Role: rbac.RoleAdmin}.OwnerScope())
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"url-shortener/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalKey is the context key the authenticated Principal is stored under.
+const PrincipalKey = "principal"
+
+// Principal is the user a request is made on behalf of.
+type Principal struct {
+	UserID int64
+	Name   string
+	Role   rbac.Role
+}
+
+// Can reports whether the role of the principal grants perm.
+func (p Principal) Can(perm rbac.Permission) bool {
+	return p.Role.Can(perm)
+}
+
+// OwnerScope returns the owner id link operations of the principal are
+// restricted to. Principals allowed to change any link are not restricted,
+// which zero stands for.
+func (p Principal) OwnerScope() int64 {
+	if p.Can(rbac.PermLinksAny) {
+		return 0
+	}
+	return p.UserID
+}
+
+// SetPrincipal stores p as the authenticated principal of the request.
+func SetPrincipal(c *gin.Context, p Principal) {
+	c.Set(PrincipalKey, p)
+	c.Set(gin.AuthUserKey, p.Name)
+}
+
+// FromContext returns the authenticated principal of the request, or the zero
+// Principal when the request was not authenticated.
+func FromContext(c *gin.Context) Principal {
+	p, _ := c.Get(PrincipalKey)
+	principal, _ := p.(Principal)
+
+	return principal
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Require rejects requests whose principal lacks perm with 403. It has to run
+// after New, requests that were not authenticated have no role and are
+// rejected as well.
+func Require(log *slog.Logger, perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const op = "middleware.auth.Require"
+
+		principal := FromContext(c)
+		if principal.Can(perm) {
+			return
+		}
+
+		log.Info("permission denied",
+			slog.String("op", op),
+			slog.String("request_id", c.GetString("request_id")),
+			slog.String("user", principal.Name),
+			slog.String("role", string(principal.Role)),
+			slog.String("permission", string(perm)),
+		)
+
+		c.AbortWithStatusJSON(http.StatusForbidden, resp.Error("permission denied"))
+	}
+}
@@ -0,0 +1,87 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name          string
+		authenticated bool
+		role          rbac.Role
+		perm          rbac.Permission
+		status        int
+	}{
+		{
+			name:          "Granted",
+			authenticated: true,
+			role:          rbac.RoleEditor,
+			perm:          rbac.PermLinksWrite,
+			status:        http.StatusOK,
+		},
+		{
+			name:          "Denied",
+			authenticated: true,
+			role:          rbac.RoleViewer,
+			perm:          rbac.PermLinksWrite,
+			status:        http.StatusForbidden,
+		},
+		{
+			name:          "Unknown role",
+			authenticated: true,
+			role:          "root",
+			perm:          rbac.PermLinksRead,
+			status:        http.StatusForbidden,
+		},
+		{
+			name:   "Not authenticated",
+			perm:   rbac.PermLinksRead,
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			router := gin.New()
+			if tc.authenticated {
+				router.Use(func(c *gin.Context) {
+					auth.SetPrincipal(c, auth.Principal{UserID: 5, Name: "pedro", Role: tc.role})
+				})
+			}
+			router.Use(auth.Require(slogdiscard.NewDiscardLogger(), tc.perm))
+
+			handled := false
+			router.GET("/", func(c *gin.Context) {
+				handled = true
+				c.Status(http.StatusOK)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+			require.Equal(t, tc.status == http.StatusOK, handled)
+			if tc.status == http.StatusForbidden {
+				require.Contains(t, rr.Body.String(), "permission denied")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package middleware_test
+
+import (
+	"testing"
+
+	middleware "url-shortener/internal/http-server/middleware/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogFormatter_MasksPassword(t *testing.T) {
+	cases := []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "Plain key",
+			path: "/docs?password=secret&utm_source=mail",
+			want: "/docs?password=[redacted]&utm_source=mail",
+		},
+		{
+			name: "Encoded key",
+			path: "/docs?pass%77ord=secret",
+			want: "/docs?pass%77ord=[redacted]",
+		},
+		{
+			name: "Undecodable key",
+			path: "/docs?pass%zzword=secret",
+			want: "/docs?[redacted]",
+		},
+		{
+			name: "No query",
+			path: "/docs",
+			want: "/docs",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			line := middleware.AccessLogFormatter(gin.LogFormatterParams{Path: tc.path})
+			require.Contains(t, line, `"`+tc.want+`"`)
+			require.NotContains(t, line, "secret")
+		})
+	}
+}
@@ -2,11 +2,26 @@ package routes
 
 import (
 	"url-shortener/internal/config"
+	"url-shortener/internal/domainpolicy"
+	auditlog "url-shortener/internal/http-server/handlers/audit"
 	"url-shortener/internal/http-server/handlers/delete"
+	"url-shortener/internal/http-server/handlers/info"
+	"url-shortener/internal/http-server/handlers/keys"
+	"url-shortener/internal/http-server/handlers/list"
 	"url-shortener/internal/http-server/handlers/redirect"
 	"url-shortener/internal/http-server/handlers/save"
+	"url-shortener/internal/http-server/handlers/stats"
+	"url-shortener/internal/http-server/handlers/update"
+	"url-shortener/internal/http-server/handlers/users"
+	"url-shortener/internal/http-server/middleware/auth"
 	middleware "url-shortener/internal/http-server/middleware/logger"
-	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/throttle"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/ssrf"
+	"url-shortener/internal/storage"
 
 	"log/slog"
 	"net/http"
@@ -14,11 +29,27 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(log *slog.Logger, storage *postgres.Storage, cfg *config.Config) *gin.Engine {
+func SetupRouter(
+	log *slog.Logger,
+	store storage.Storage,
+	clickRecorder redirect.ClickRecorder,
+	aliases *alias.Allocator,
+	destinations *domainpolicy.Policy,
+	guard *ssrf.Guard,
+	tokens auth.TokenVerifier,
+	cfg *config.Config,
+) *gin.Engine {
 	gin.SetMode(cfg.GinMode)
 	router := gin.New()
 
-	router.Use(gin.Logger())
+	// X-Forwarded-For is honoured only from the configured proxies, so clients
+	// cannot forge the IP recorded for clicks and in the audit log.
+	if err := router.SetTrustedProxies(cfg.HTTPServer.TrustedProxies); err != nil {
+		log.Error("invalid trusted proxies, trusting none", sl.Err(err))
+		_ = router.SetTrustedProxies(nil)
+	}
+
+	router.Use(gin.LoggerWithFormatter(middleware.AccessLogFormatter))
 	router.Use(gin.Recovery())
 	router.Use(middleware.RequestIDMiddleware())
 	router.Use(middleware.LoggerMiddleware(log))
@@ -27,20 +58,74 @@ func SetupRouter(log *slog.Logger, storage *postgres.Storage, cfg *config.Config
 		c.String(http.StatusOK, "Welcome to the URL Shortener!")
 	})
 
-	api := router.Group("/api")
+	normalizer := urlnorm.New(urlnorm.Options{
+		SortQuery:  cfg.Normalize.SortQuery,
+		DropParams: cfg.Normalize.DropParams,
+	})
+
+	api := router.Group("/api", normalizeAlias(aliases.Policy()))
 	{
-		api.GET("/:alias", redirect.New(log, storage))
+		passwordAttempts := throttle.New(cfg.LinkPassword.MaxFailures, cfg.LinkPassword.LockWindow)
+		redirectHandler := redirect.New(log, store, clickRecorder, destinations, passwordAttempts)
+
+		// Password forms of protected links are posted back to the link itself.
+		for _, path := range []string{"/:alias", "/:alias/*path"} {
+			api.GET(path, redirectHandler)
+			api.POST(path, redirectHandler)
+		}
 
-		auth := gin.BasicAuth(gin.Accounts{
+		accounts := gin.Accounts{
 			cfg.HTTPServer.User: cfg.HTTPServer.Password,
-		})
+		}
+
+		authenticate := auth.New(log, store, accounts, tokens)
+
+		// Every route requires the permission of its group, see rbac for the
+		// permissions each role grants. Reads are scoped like changes, so a
+		// viewer key shows exactly the links of its user.
+		read := api.Group("/", authenticate, auth.Require(log, rbac.PermLinksRead))
+		{
+			read.GET("/links", list.New(log, store))
+			read.GET("/link/:alias", info.New(log, store))
+			read.GET("/link/:alias/stats", stats.New(log, store))
+		}
 
-		apiWithAuth := api.Group("/", auth)
+		// Editors change their own links only, the handlers scope changes
+		// with Principal.OwnerScope.
+		write := api.Group("/", authenticate, auth.Require(log, rbac.PermLinksWrite))
 		{
-			apiWithAuth.POST("/save", save.New(log, storage, cfg))
-			apiWithAuth.DELETE("/link/:alias", delete.Delete(log, storage))
+			write.POST("/save", save.New(log, store, aliases, normalizer, destinations, guard, cfg))
+			write.POST("/save/batch", save.NewBatch(log, store, aliases, normalizer, destinations, guard, cfg))
+			write.PATCH("/link/:alias", update.New(log, store, normalizer, destinations, guard))
+			write.DELETE("/link/:alias", delete.Delete(log, store))
+		}
+
+		admin := api.Group("/admin", authenticate, auth.Require(log, rbac.PermUsersManage))
+		{
+			admin.POST("/users", users.NewCreate(log, store))
+			admin.GET("/users", users.NewList(log, store))
+			admin.POST("/keys", keys.NewCreate(log, store))
+			admin.GET("/keys", keys.NewList(log, store))
+			admin.DELETE("/keys/:id", keys.NewRevoke(log, store))
+		}
+
+		audited := api.Group("/audit", authenticate, auth.Require(log, rbac.PermAuditRead))
+		{
+			audited.GET("", auditlog.New(log, store))
 		}
 	}
 
 	return router
 }
+
+// normalizeAlias rewrites the :alias path parameter to the form aliases are
+// stored in, so case-insensitive aliases resolve whatever case they are typed in.
+func normalizeAlias(policy *alias.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for i := range c.Params {
+			if c.Params[i].Key == "alias" {
+				c.Params[i].Value = policy.Normalize(c.Params[i].Value)
+			}
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/http-server/handlers/redirect/mocks"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/http-server/routes"
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/oidc"
+	"url-shortener/internal/rbac"
+	"url-shortener/internal/ssrf"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// permissions lists the permission every authenticated route requires.
+var permissions = []struct {
+	method string
+	path   string
+	perm   rbac.Permission
+}{
+	{http.MethodGet, "/api/links", rbac.PermLinksRead},
+	{http.MethodGet, "/api/link/:alias", rbac.PermLinksRead},
+	{http.MethodGet, "/api/link/:alias/stats", rbac.PermLinksRead},
+	{http.MethodPost, "/api/save", rbac.PermLinksWrite},
+	{http.MethodPost, "/api/save/batch", rbac.PermLinksWrite},
+	{http.MethodPatch, "/api/link/:alias", rbac.PermLinksWrite},
+	{http.MethodDelete, "/api/link/:alias", rbac.PermLinksWrite},
+	{http.MethodPost, "/api/admin/users", rbac.PermUsersManage},
+	{http.MethodGet, "/api/admin/users", rbac.PermUsersManage},
+	{http.MethodPost, "/api/admin/keys", rbac.PermUsersManage},
+	{http.MethodGet, "/api/admin/keys", rbac.PermUsersManage},
+	{http.MethodDelete, "/api/admin/keys/:id", rbac.PermUsersManage},
+	{http.MethodGet, "/api/audit", rbac.PermAuditRead},
+}
+
+// public lists the routes served without authentication.
+var public = map[string]bool{
+	"GET /":                  true,
+	"GET /api/:alias":        true,
+	"POST /api/:alias":       true,
+	"GET /api/:alias/*path":  true,
+	"POST /api/:alias/*path": true,
+}
+
+func TestSetupRouter_Permissions(t *testing.T) {
+	store := memory.New()
+	router := newRouter(t, store)
+
+	tokens := make(map[rbac.Role]string)
+	for _, role := range rbac.Roles {
+		userID, err := store.CreateUser(storage.User{Name: string(role), Role: string(role)})
+		require.NoError(t, err)
+
+		token, err := apikey.Generate()
+		require.NoError(t, err)
+
+		_, err = store.CreateAPIKey(storage.APIKey{Name: string(role), UserID: userID, Prefix: apikey.Prefix(token), Hash: apikey.Hash(token)})
+		require.NoError(t, err)
+
+		tokens[role] = token
+	}
+
+	for _, route := range permissions {
+		route := route
+
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			rr := serve(router, route.method, route.path, "")
+			require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+			for _, role := range rbac.Roles {
+				rr := serve(router, route.method, route.path, "Bearer "+tokens[role])
+
+				if role.Can(route.perm) {
+					require.NotContains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, rr.Code, role)
+				} else {
+					require.Equal(t, http.StatusForbidden, rr.Code, role)
+					require.Contains(t, rr.Body.String(), "permission denied", role)
+				}
+			}
+		})
+	}
+}
+
+// TestSetupRouter_EveryRouteHasPermission keeps routes from being added
+// without deciding on the permission they require.
+func TestSetupRouter_EveryRouteHasPermission(t *testing.T) {
+	router := newRouter(t, memory.New())
+
+	known := make(map[string]bool)
+	for _, route := range permissions {
+		known[route.method+" "+route.path] = true
+	}
+
+	for _, route := range router.Routes() {
+		key := route.Method + " " + route.Path
+		require.True(t, known[key] || public[key], "no permission listed for %s", key)
+	}
+}
+
+// TestSetupRouter_SSO signs in with a JWT verified against a local JWKS
+// server and checks save and delete act on behalf of its subject.
+func TestSetupRouter_SSO(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	public, err := key.PublicKey.ECDH()
+	require.NoError(t, err)
+	point := public.Bytes()
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": "sso-1",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(point[1:33]),
+			"y":   base64.RawURLEncoding.EncodeToString(point[33:]),
+		}}})
+	}))
+	t.Cleanup(jwks.Close)
+
+	log := slogdiscard.NewDiscardLogger()
+
+	keys, err := oidc.NewKeySet(context.Background(), log, "", jwks.URL, nil)
+	require.NoError(t, err)
+
+	tokens := oidc.NewVerifier(keys, oidc.Options{
+		Issuer: "https://sso.example.com",
+		Roles:  map[string]rbac.Role{"shortener-editors": rbac.RoleEditor},
+	})
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss":    "https://sso.example.com",
+		"sub":    "maria",
+		"groups": []string{"shortener-editors"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "sso-1"
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	store := memory.New()
+	router := newRouterWithTokens(t, store, tokens)
+	bearer := "Bearer " + signed
+
+	rr := serveBody(router, http.MethodPost, "/api/save", bearer, `{"url": "https://93.184.216.34/page", "alias": "sso-link"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	user, err := store.GetUserByName("maria")
+	require.NoError(t, err)
+	require.Equal(t, string(rbac.RoleEditor), user.Role)
+
+	link, err := store.GetLink("sso-link")
+	require.NoError(t, err)
+	require.Equal(t, "maria", link.Owner)
+	require.Equal(t, user.ID, link.OwnerID)
+
+	rr = serveBody(router, http.MethodDelete, "/api/link/sso-link", bearer, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = serveBody(router, http.MethodGet, "/api/links", "Bearer "+signed[:len(signed)-4]+"AAAA", "")
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+// TestSetupRouter_Audit checks link changes end up in the audit log along
+// with the request they were made by.
+func TestSetupRouter_Audit(t *testing.T) {
+	store := memory.New()
+	router := newRouter(t, store)
+
+	_, err := store.CreateUser(storage.User{Name: "pedro", Role: string(rbac.RoleAdmin)})
+	require.NoError(t, err)
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("pedro:d123"))
+
+	rr := serveBody(router, http.MethodPost, "/api/save", basic, `{"url": "https://93.184.216.34/page", "alias": "audited", "password": "secret"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = serveBody(router, http.MethodPatch, "/api/link/audited", basic, `{"url": "https://93.184.216.34/other"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// No proxies are trusted by default, so a forged X-Forwarded-For is ignored.
+	req := httptest.NewRequest(http.MethodDelete, "/api/link/audited", nil)
+	req.Header.Set("Authorization", basic)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	requestID := rr.Header().Get("X-Request-Id")
+
+	rr = serveBody(router, http.MethodGet, "/api/audit?alias=audited", basic, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NotContains(t, rr.Body.String(), "$2a$", "password hashes must not be recorded")
+
+	var res struct {
+		Entries []struct {
+			Actor     string         `json:"actor"`
+			Action    string         `json:"action"`
+			Before    map[string]any `json:"before"`
+			After     map[string]any `json:"after"`
+			RequestID string         `json:"request_id"`
+			ClientIP  string         `json:"client_ip"`
+		} `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	require.Len(t, res.Entries, 3)
+
+	deleted, updated, created := res.Entries[0], res.Entries[1], res.Entries[2]
+
+	require.Equal(t, "link.create", created.Action)
+	require.Nil(t, created.Before)
+	require.Equal(t, true, created.After["password_protected"])
+
+	require.Equal(t, "link.update", updated.Action)
+	require.Equal(t, "https://93.184.216.34/page", updated.Before["url"])
+	require.Equal(t, "https://93.184.216.34/other", updated.After["url"])
+
+	require.Equal(t, "link.delete", deleted.Action)
+	require.Nil(t, deleted.After)
+	require.Equal(t, "pedro", deleted.Actor)
+	require.Equal(t, requestID, deleted.RequestID)
+	require.Equal(t, "192.0.2.1", deleted.ClientIP)
+}
+
+func newRouter(t *testing.T, store storage.Storage) *gin.Engine {
+	t.Helper()
+
+	return newRouterWithTokens(t, store, nil)
+}
+
+func newRouterWithTokens(t *testing.T, store storage.Storage, tokens auth.TokenVerifier) *gin.Engine {
+	t.Helper()
+
+	log := slogdiscard.NewDiscardLogger()
+
+	cfg := &config.Config{
+		Redirect:   config.Redirect{DefaultStatus: http.StatusFound},
+		HTTPServer: config.HTTPServer{User: "pedro", Password: "d123"},
+	}
+
+	guard, err := ssrf.New(log, ssrf.Options{})
+	require.NoError(t, err)
+
+	aliases := alias.New(log, alias.Options{Length: 6})
+
+	return routes.SetupRouter(log, store, mocks.NewClickRecorder(t), aliases, nil, guard, tokens, cfg)
+}
+
+func serve(router http.Handler, method, path, authorization string) *httptest.ResponseRecorder {
+	path = strings.NewReplacer(":alias", "missing", ":id", "42").Replace(path)
+
+	// Empty bodies are rejected by validation before any handler reaches out
+	// to the network.
+	return serveBody(router, method, path, authorization, "{}")
+}
+
+func serveBody(router http.Handler, method, path, authorization, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
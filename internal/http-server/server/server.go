@@ -1,12 +1,17 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"url-shortener/internal/config"
+	"url-shortener/internal/lib/logger/sl"
 )
 
-func Start(log *slog.Logger, cfg *config.Config, router http.Handler) {
+// Start serves router until ctx is cancelled and then shuts the server down
+// gracefully, letting in-flight requests finish within the shutdown timeout.
+func Start(ctx context.Context, log *slog.Logger, cfg *config.Config, router http.Handler) {
 	srv := &http.Server{
 		Addr:         cfg.Address,
 		Handler:      router,
@@ -17,9 +22,26 @@ func Start(log *slog.Logger, cfg *config.Config, router http.Handler) {
 
 	log.Info("starting server", slog.String("address", cfg.Address))
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Error("failed to start server")
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Error("failed to start server", sl.Err(err))
+		}
+	case <-ctx.Done():
+		log.Info("shutting down server")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error("failed to shut down server", sl.Err(err))
+		}
 	}
 
-	log.Error("server stopped")
+	log.Info("server stopped")
 }
@@ -0,0 +1,90 @@
+package janitor
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+)
+
+type ExpiredRemover interface {
+	DeleteExpired(before time.Time, limit int, audit storage.AuditEntry) (int64, error)
+}
+
+// Janitor periodically purges expired links from storage.
+type Janitor struct {
+	log       *slog.Logger
+	remover   ExpiredRemover
+	interval  time.Duration
+	batchSize int
+}
+
+// Defaults used by New in place of an interval or batch size that is not
+// positive.
+const (
+	DefaultInterval  = time.Minute
+	DefaultBatchSize = 500
+)
+
+func New(log *slog.Logger, remover ExpiredRemover, interval time.Duration, batchSize int) *Janitor {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	return &Janitor{
+		log:       log.With(slog.String("component", "janitor")),
+		remover:   remover,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run purges expired links every interval until ctx is cancelled.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := j.Purge(ctx)
+			if err != nil {
+				j.log.Error("failed to purge expired links", sl.Err(err))
+			}
+			if deleted > 0 {
+				j.log.Info("expired links purged", slog.Int64("deleted", deleted))
+			}
+		}
+	}
+}
+
+// Purge deletes expired links in batches until no full batch is left and
+// returns the total number of deleted links. Every deleted link is recorded
+// in the audit log on behalf of the system.
+func (j *Janitor) Purge(ctx context.Context) (int64, error) {
+	var total int64
+
+	for ctx.Err() == nil {
+		deleted, err := j.remover.DeleteExpired(time.Now(), j.batchSize, storage.AuditEntry{
+			Actor:  storage.SystemActor,
+			Action: storage.ActionLinkPurge,
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+
+		if deleted < int64(j.batchSize) {
+			break
+		}
+	}
+
+	return total, nil
+}
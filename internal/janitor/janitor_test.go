@@ -0,0 +1,74 @@
+package janitor_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"url-shortener/internal/janitor"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJanitor_Purge(t *testing.T) {
+	s := memory.New()
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	for _, alias := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, s.SaveURL(storage.Link{Alias: alias, URL: "https://example.com", ExpiresAt: &past}))
+	}
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "future", URL: "https://example.com", ExpiresAt: &future}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "forever", URL: "https://example.com"}))
+
+	j := janitor.New(slogdiscard.NewDiscardLogger(), s, time.Minute, 2)
+
+	deleted, err := j.Purge(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(5), deleted)
+
+	_, err = s.GetURL("future")
+	require.NoError(t, err)
+
+	_, err = s.GetURL("forever")
+	require.NoError(t, err)
+
+	entries, err := s.ListAudit(storage.AuditFilter{Action: storage.ActionLinkPurge, Limit: 10})
+	require.NoError(t, err)
+
+	var purged []string
+	for _, entry := range entries {
+		require.Equal(t, storage.SystemActor, entry.Actor)
+		purged = append(purged, entry.Alias)
+
+		var before storage.LinkState
+		require.NoError(t, json.Unmarshal([]byte(entry.Before), &before))
+		require.Equal(t, entry.Alias, before.Alias)
+		require.Equal(t, "https://example.com", before.URL)
+	}
+	require.ElementsMatch(t, []string{"a", "b", "c", "d", "e"}, purged)
+}
+
+func TestJanitor_Defaults(t *testing.T) {
+	s := memory.New()
+
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "a", URL: "https://example.com", ExpiresAt: &past}))
+
+	// A zero batch size would never finish a batch and a zero interval would
+	// panic in Run, both fall back to the defaults.
+	j := janitor.New(slogdiscard.NewDiscardLogger(), s, 0, 0)
+
+	deleted, err := j.Purge(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	j.Run(ctx)
+}
@@ -0,0 +1,188 @@
+package alias
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"url-shortener/internal/storage"
+)
+
+var ErrAttemptsExhausted = errors.New("no free alias found")
+
+// Strategy describes how a generated alias was obtained.
+type Strategy string
+
+const (
+	// StrategyFirst is a first-try alias of the current length.
+	StrategyFirst Strategy = "first"
+	// StrategyRetry is an alias of the current length picked after collisions.
+	StrategyRetry Strategy = "retry"
+	// StrategyGrow is an alias one character longer than the current length,
+	// used once half of the attempts of a request have collided.
+	StrategyGrow Strategy = "grow"
+)
+
+type Options struct {
+	// Generator produces the aliases, Random when nil.
+	Generator Generator
+	// Policy normalizes generated aliases and keeps them off the reserved
+	// list. Nil allows any alias.
+	Policy *Policy
+	// Length is the initial alias length.
+	Length int
+	// MaxLength caps the growth of the alias length.
+	MaxLength int
+	// MaxAttempts is the number of aliases tried per link before giving up.
+	MaxAttempts int
+	// GrowThreshold is the share of colliding attempts within GrowWindow
+	// attempts after which the alias length is increased for good.
+	GrowThreshold float64
+	// GrowWindow is the number of attempts the collision rate is measured over.
+	GrowWindow int
+}
+
+// Result describes a successfully stored generated alias.
+type Result struct {
+	Alias    string
+	Strategy Strategy
+	Attempts int
+}
+
+// Allocator generates aliases with a Generator, retries them on collisions and grows
+// their length when the keyspace gets crowded. It is safe for concurrent use.
+type Allocator struct {
+	log  *slog.Logger
+	opts Options
+
+	mu         sync.Mutex
+	length     int
+	attempts   int
+	collisions int
+}
+
+func New(log *slog.Logger, opts Options) *Allocator {
+	if opts.MaxLength < opts.Length {
+		opts.MaxLength = opts.Length
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+	if opts.Generator == nil {
+		opts.Generator = Random{}
+	}
+
+	return &Allocator{
+		log:    log,
+		opts:   opts,
+		length: opts.Length,
+	}
+}
+
+// Length returns the current length of freshly generated aliases.
+func (a *Allocator) Length() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.length
+}
+
+// Policy returns the policy custom aliases are validated against.
+func (a *Allocator) Policy() *Policy {
+	return a.opts.Policy
+}
+
+// MaxAttempts returns the number of aliases tried per link.
+func (a *Allocator) MaxAttempts() int {
+	return a.opts.MaxAttempts
+}
+
+// Generate returns a candidate alias for the given 1-based attempt.
+func (a *Allocator) Generate(attempt int) (string, Strategy, error) {
+	const op = "lib.alias.Generate"
+
+	length := a.Length()
+	strategy := StrategyFirst
+
+	switch {
+	case attempt > 1 && attempt > a.opts.MaxAttempts/2 && length < a.opts.MaxLength:
+		length++
+		strategy = StrategyGrow
+	case attempt > 1:
+		strategy = StrategyRetry
+	}
+
+	for {
+		alias, err := a.opts.Generator.Generate(length)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		alias = a.opts.Policy.Normalize(alias)
+		if !a.opts.Policy.Reserved(alias) {
+			return alias, strategy, nil
+		}
+	}
+}
+
+// Observe records that collisions out of attempts generated aliases were
+// already taken, growing the alias length when the collision rate over the
+// last window reaches the threshold.
+func (a *Allocator) Observe(attempts, collisions int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.attempts += attempts
+	a.collisions += collisions
+
+	if a.opts.GrowWindow <= 0 || a.attempts < a.opts.GrowWindow {
+		return
+	}
+
+	rate := float64(a.collisions) / float64(a.attempts)
+	a.attempts, a.collisions = 0, 0
+
+	if rate < a.opts.GrowThreshold || a.length >= a.opts.MaxLength {
+		return
+	}
+
+	a.length++
+
+	a.log.Warn("alias keyspace is crowded, growing alias length",
+		slog.Float64("collision_rate", rate),
+		slog.Int("length", a.length),
+	)
+}
+
+// Save generates aliases and passes them to save until it succeeds or
+// returns an error other than storage.ErrURLExists.
+func (a *Allocator) Save(save func(alias string) error) (Result, error) {
+	const op = "lib.alias.Save"
+
+	for attempt := 1; attempt <= a.opts.MaxAttempts; attempt++ {
+		alias, strategy, err := a.Generate(attempt)
+		if err != nil {
+			return Result{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = save(alias)
+		if errors.Is(err, storage.ErrURLExists) {
+			a.Observe(1, 1)
+			continue
+		}
+		if err != nil {
+			return Result{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		a.Observe(1, 0)
+
+		return Result{
+			Alias:    alias,
+			Strategy: strategy,
+			Attempts: attempt,
+		}, nil
+	}
+
+	return Result{}, fmt.Errorf("%s: %w after %d attempts", op, ErrAttemptsExhausted, a.opts.MaxAttempts)
+}
@@ -0,0 +1,119 @@
+package alias_test
+
+import (
+	"errors"
+	"testing"
+
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocator_Save(t *testing.T) {
+	cases := []struct {
+		name       string
+		collisions int
+		saveErr    error
+		wantErr    error
+		strategy   alias.Strategy
+		length     int
+	}{
+		{
+			name:     "First attempt",
+			strategy: alias.StrategyFirst,
+			length:   6,
+		},
+		{
+			name:       "Retry at the same length",
+			collisions: 1,
+			strategy:   alias.StrategyRetry,
+			length:     6,
+		},
+		{
+			name:       "Grow after half of the attempts",
+			collisions: 2,
+			strategy:   alias.StrategyGrow,
+			length:     7,
+		},
+		{
+			name:       "Attempts exhausted",
+			collisions: 4,
+			wantErr:    alias.ErrAttemptsExhausted,
+		},
+		{
+			name:    "Storage error is not retried",
+			saveErr: errors.New("connection refused"),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			a := alias.New(slogdiscard.NewDiscardLogger(), alias.Options{
+				Length:      6,
+				MaxLength:   8,
+				MaxAttempts: 4,
+			})
+
+			var tried []string
+			res, err := a.Save(func(generated string) error {
+				tried = append(tried, generated)
+				if tc.saveErr != nil {
+					return tc.saveErr
+				}
+				if len(tried) <= tc.collisions {
+					return storage.ErrURLExists
+				}
+				return nil
+			})
+
+			switch {
+			case tc.saveErr != nil:
+				require.ErrorIs(t, err, tc.saveErr)
+				require.Len(t, tried, 1)
+				return
+			case tc.wantErr != nil:
+				require.ErrorIs(t, err, tc.wantErr)
+				require.Len(t, tried, a.MaxAttempts())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.strategy, res.Strategy)
+			require.Equal(t, tc.collisions+1, res.Attempts)
+			require.Len(t, res.Alias, tc.length)
+			require.Equal(t, tried[len(tried)-1], res.Alias)
+		})
+	}
+}
+
+func TestAllocator_Observe(t *testing.T) {
+	a := alias.New(slogdiscard.NewDiscardLogger(), alias.Options{
+		Length:        4,
+		MaxLength:     5,
+		MaxAttempts:   3,
+		GrowThreshold: 0.5,
+		GrowWindow:    10,
+	})
+
+	a.Observe(10, 4)
+	require.Equal(t, 4, a.Length(), "collision rate below threshold")
+
+	a.Observe(6, 3)
+	require.Equal(t, 4, a.Length(), "window not filled yet")
+
+	a.Observe(4, 2)
+	require.Equal(t, 5, a.Length())
+
+	a.Observe(10, 10)
+	require.Equal(t, 5, a.Length(), "length is capped")
+
+	generated, _, err := a.Generate(1)
+	require.NoError(t, err)
+	require.Len(t, generated, 5)
+}
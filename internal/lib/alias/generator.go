@@ -0,0 +1,65 @@
+package alias
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"url-shortener/internal/lib/random"
+)
+
+// Generator produces candidate aliases of the requested length.
+type Generator interface {
+	Generate(length int) (string, error)
+}
+
+const (
+	GeneratorRandom        = "random"
+	GeneratorSequential    = "sequential"
+	GeneratorPronounceable = "pronounceable"
+	GeneratorUnambiguous   = "unambiguous"
+)
+
+// UnambiguousAlphabet is base62 without the characters that are easy to
+// confuse when an alias is read aloud or retyped: 0/O and 1/l/I.
+const UnambiguousAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ" +
+	"abcdefghijkmnopqrstuvwxyz" +
+	"23456789"
+
+// NewGenerator returns the generator registered under name. The salt only
+// affects the sequential generator.
+func NewGenerator(name, salt string) (Generator, error) {
+	const op = "lib.alias.NewGenerator"
+
+	switch name {
+	case GeneratorRandom, "":
+		return Random{}, nil
+	case GeneratorSequential:
+		// Starting from the current time in milliseconds keeps the counter
+		// ahead of the values issued before a restart as long as less than
+		// a thousand aliases per second are generated.
+		return NewSequential(salt, uint64(time.Now().UnixMilli())), nil
+	case GeneratorPronounceable:
+		return Pronounceable{}, nil
+	case GeneratorUnambiguous:
+		return Unambiguous{}, nil
+	default:
+		return nil, fmt.Errorf("%s: unknown alias generator %q, expected one of: %s", op, name, strings.Join([]string{
+			GeneratorRandom, GeneratorSequential, GeneratorPronounceable, GeneratorUnambiguous,
+		}, ", "))
+	}
+}
+
+// Random generates uniformly random base62 aliases.
+type Random struct{}
+
+func (Random) Generate(length int) (string, error) {
+	return random.NewRandomString(length)
+}
+
+// Unambiguous generates uniformly random aliases from UnambiguousAlphabet.
+type Unambiguous struct{}
+
+func (Unambiguous) Generate(length int) (string, error) {
+	return random.NewString(UnambiguousAlphabet, length)
+}
@@ -0,0 +1,168 @@
+package alias_test
+
+import (
+	"strings"
+	"testing"
+
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/random"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerators(t *testing.T) {
+	const samples = 20000
+
+	cases := []struct {
+		name   string
+		gen    alias.Generator
+		length int
+		// alphabets[i%len(alphabets)] is the alphabet of position i.
+		alphabets []string
+	}{
+		{
+			name:      "Random",
+			gen:       alias.Random{},
+			length:    8,
+			alphabets: []string{random.Base62},
+		},
+		{
+			name:      "Unambiguous",
+			gen:       alias.Unambiguous{},
+			length:    8,
+			alphabets: []string{alias.UnambiguousAlphabet},
+		},
+		{
+			name:      "Sequential",
+			gen:       alias.NewSequential("salt", 0),
+			length:    8,
+			alphabets: []string{random.Base62},
+		},
+		{
+			name:      "Pronounceable",
+			gen:       alias.Pronounceable{},
+			length:    12,
+			alphabets: []string{"bdfghjkmnprstvz", "aeiou"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			seen := make(map[string]struct{}, samples)
+			counts := make([]map[rune]int, len(tc.alphabets))
+			for k := range counts {
+				counts[k] = make(map[rune]int)
+			}
+
+			for i := 0; i < samples; i++ {
+				a, err := tc.gen.Generate(tc.length)
+				require.NoError(t, err)
+				require.Len(t, a, tc.length)
+
+				_, dup := seen[a]
+				require.False(t, dup, "duplicate alias %q", a)
+				seen[a] = struct{}{}
+
+				for i, r := range a {
+					k := i % len(tc.alphabets)
+					require.True(t, strings.ContainsRune(tc.alphabets[k], r), "unexpected character %q in %q", r, a)
+					counts[k][r]++
+				}
+			}
+
+			// Every character is expected to show up within 20% of its
+			// uniform share among the positions using its alphabet.
+			for k, alphabet := range tc.alphabets {
+				positions := (tc.length - k + len(tc.alphabets) - 1) / len(tc.alphabets)
+				expected := float64(samples*positions) / float64(len(alphabet))
+				for _, r := range alphabet {
+					require.InDelta(t, expected, float64(counts[k][r]), expected*0.2, "character %q", r)
+				}
+			}
+		})
+	}
+}
+
+func TestSequential_Permutation(t *testing.T) {
+	// With two characters the keyspace holds 62^2 aliases, and the first
+	// 62^2 counter values must map onto all of them.
+	gen := alias.NewSequential("salt", 0)
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 62*62; i++ {
+		a, err := gen.Generate(2)
+		require.NoError(t, err)
+		seen[a] = struct{}{}
+	}
+
+	require.Len(t, seen, 62*62)
+}
+
+func TestSequential_Obfuscation(t *testing.T) {
+	gen := alias.NewSequential("salt", 1000)
+	other := alias.NewSequential("pepper", 1000)
+
+	prev, err := gen.Generate(8)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		next, err := gen.Generate(8)
+		require.NoError(t, err)
+
+		same := 0
+		for j := range next {
+			if next[j] == prev[j] {
+				same++
+			}
+		}
+		require.Less(t, same, 4, "%q and %q look related", prev, next)
+
+		prev = next
+	}
+
+	a, err := alias.NewSequential("salt", 1000).Generate(8)
+	require.NoError(t, err)
+
+	b, err := other.Generate(8)
+	require.NoError(t, err)
+
+	require.NotEqual(t, a, b, "salt must change the encoding")
+}
+
+func TestPronounceable_Pattern(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		a, err := alias.Pronounceable{}.Generate(7)
+		require.NoError(t, err)
+
+		for j, r := range a {
+			if j%2 == 0 {
+				require.NotContains(t, "aeiou", string(r), "consonant expected at %d in %q", j, a)
+			} else {
+				require.Contains(t, "aeiou", string(r), "vowel expected at %d in %q", j, a)
+			}
+		}
+	}
+}
+
+func TestNewGenerator(t *testing.T) {
+	for _, name := range []string{
+		alias.GeneratorRandom,
+		alias.GeneratorSequential,
+		alias.GeneratorPronounceable,
+		alias.GeneratorUnambiguous,
+	} {
+		gen, err := alias.NewGenerator(name, "salt")
+		require.NoError(t, err, name)
+
+		a, err := gen.Generate(10)
+		require.NoError(t, err, name)
+		require.Len(t, a, 10, name)
+	}
+
+	_, err := alias.NewGenerator("sqids", "")
+	require.Error(t, err)
+}
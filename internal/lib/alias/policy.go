@@ -0,0 +1,118 @@
+package alias
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// Tag validates a custom alias against the policy registered with
+	// Policy.Register. It expands to the length, charset and reserved checks.
+	Tag = "alias"
+
+	tagCharset  = "alias_charset"
+	tagReserved = "alias_reserved"
+)
+
+type PolicyOptions struct {
+	// Charset is the body of a regexp character class, e.g. "a-zA-Z0-9_-".
+	// Empty allows any character.
+	Charset string
+	// MinLength and MaxLength bound the alias length in characters, zero
+	// disables the respective bound.
+	MinLength int
+	MaxLength int
+	// CaseSensitive keeps "Promo" and "promo" distinct aliases. Otherwise
+	// aliases are stored and resolved in lower case.
+	CaseSensitive bool
+	// Reserved aliases are rejected, typically because they clash with routes.
+	Reserved []string
+}
+
+// Policy restricts the aliases users may choose.
+type Policy struct {
+	opts     PolicyOptions
+	charset  *regexp.Regexp
+	reserved map[string]struct{}
+}
+
+func NewPolicy(opts PolicyOptions) (*Policy, error) {
+	const op = "lib.alias.NewPolicy"
+
+	if opts.MaxLength > 0 && opts.MinLength > opts.MaxLength {
+		return nil, fmt.Errorf("%s: min length %d exceeds max length %d", op, opts.MinLength, opts.MaxLength)
+	}
+
+	p := &Policy{
+		opts:     opts,
+		reserved: make(map[string]struct{}, len(opts.Reserved)),
+	}
+
+	if opts.Charset != "" {
+		expr := "^[" + opts.Charset + "]*$"
+		if !opts.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid charset %q: %w", op, opts.Charset, err)
+		}
+		p.charset = re
+	}
+
+	for _, r := range opts.Reserved {
+		p.reserved[p.Normalize(r)] = struct{}{}
+	}
+
+	return p, nil
+}
+
+// Normalize returns the form an alias is stored and looked up in.
+func (p *Policy) Normalize(alias string) string {
+	if p == nil || p.opts.CaseSensitive {
+		return alias
+	}
+
+	return strings.ToLower(alias)
+}
+
+// Reserved reports whether alias is on the reserved list.
+func (p *Policy) Reserved(alias string) bool {
+	if p == nil {
+		return false
+	}
+
+	_, ok := p.reserved[p.Normalize(alias)]
+	return ok
+}
+
+// Register adds the Tag validation to v.
+func (p *Policy) Register(v *validator.Validate) {
+	_ = v.RegisterValidation(tagCharset, func(fl validator.FieldLevel) bool {
+		return p == nil || p.charset == nil || p.charset.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation(tagReserved, func(fl validator.FieldLevel) bool {
+		return !p.Reserved(fl.Field().String())
+	})
+
+	tags := []string{}
+	if p != nil && p.opts.MinLength > 0 {
+		tags = append(tags, fmt.Sprintf("min=%d", p.opts.MinLength))
+	}
+	if p != nil && p.opts.MaxLength > 0 {
+		tags = append(tags, fmt.Sprintf("max=%d", p.opts.MaxLength))
+	}
+	if p != nil && p.charset != nil {
+		// The charset is passed as a parameter only to be shown in
+		// validation errors, so separators of the tag syntax are escaped.
+		param := strings.NewReplacer(",", "0x2C", "|", "0x7C").Replace(p.opts.Charset)
+		tags = append(tags, tagCharset+"="+param)
+	}
+	tags = append(tags, tagReserved)
+
+	v.RegisterAlias(Tag, strings.Join(tags, ","))
+}
@@ -0,0 +1,167 @@
+package alias_test
+
+import (
+	"testing"
+
+	"url-shortener/internal/lib/alias"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	cases := []struct {
+		name          string
+		alias         string
+		caseSensitive bool
+		failedTag     string
+	}{
+		{
+			name:          "Valid",
+			alias:         "promo_24-a",
+			caseSensitive: true,
+		},
+		{
+			name:          "Too short",
+			alias:         "ab",
+			caseSensitive: true,
+			failedTag:     "min",
+		},
+		{
+			name:          "Too long",
+			alias:         "abcdefghijk",
+			caseSensitive: true,
+			failedTag:     "max",
+		},
+		{
+			name:          "Slash",
+			alias:         "a/b/c",
+			caseSensitive: true,
+			failedTag:     "alias_charset",
+		},
+		{
+			name:          "Unicode",
+			alias:         "ссылка",
+			caseSensitive: true,
+			failedTag:     "alias_charset",
+		},
+		{
+			name:          "Upper case outside of charset",
+			alias:         "Promo",
+			caseSensitive: true,
+			failedTag:     "alias_charset",
+		},
+		{
+			name:  "Upper case when case insensitive",
+			alias: "Promo",
+		},
+		{
+			name:          "Reserved",
+			alias:         "links",
+			caseSensitive: true,
+			failedTag:     "alias_reserved",
+		},
+		{
+			name:      "Reserved in another case",
+			alias:     "LINKS",
+			failedTag: "alias_reserved",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			policy, err := alias.NewPolicy(alias.PolicyOptions{
+				Charset:       "a-z0-9_-",
+				MinLength:     3,
+				MaxLength:     10,
+				CaseSensitive: tc.caseSensitive,
+				Reserved:      []string{"save", "links"},
+			})
+			require.NoError(t, err)
+
+			validate := validator.New()
+			policy.Register(validate)
+
+			err = validate.Var(tc.alias, alias.Tag)
+			if tc.failedTag == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var errs validator.ValidationErrors
+			require.ErrorAs(t, err, &errs)
+			require.Len(t, errs, 1)
+			require.Equal(t, tc.failedTag, errs[0].ActualTag())
+		})
+	}
+}
+
+func TestPolicy_CharsetParam(t *testing.T) {
+	policy, err := alias.NewPolicy(alias.PolicyOptions{Charset: "a-z,|"})
+	require.NoError(t, err)
+
+	validate := validator.New()
+	policy.Register(validate)
+
+	err = validate.Var("a.b", alias.Tag)
+
+	var errs validator.ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	require.Equal(t, "a-z,|", errs[0].Param())
+}
+
+func TestPolicy_Normalize(t *testing.T) {
+	sensitive, err := alias.NewPolicy(alias.PolicyOptions{CaseSensitive: true})
+	require.NoError(t, err)
+	require.Equal(t, "Promo", sensitive.Normalize("Promo"))
+
+	insensitive, err := alias.NewPolicy(alias.PolicyOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "promo", insensitive.Normalize("Promo"))
+
+	var none *alias.Policy
+	require.Equal(t, "Promo", none.Normalize("Promo"))
+	require.False(t, none.Reserved("save"))
+}
+
+func TestNewPolicy_Invalid(t *testing.T) {
+	_, err := alias.NewPolicy(alias.PolicyOptions{Charset: "a-"})
+	require.NoError(t, err)
+
+	_, err = alias.NewPolicy(alias.PolicyOptions{Charset: "z-a"})
+	require.Error(t, err)
+
+	_, err = alias.NewPolicy(alias.PolicyOptions{MinLength: 10, MaxLength: 5})
+	require.Error(t, err)
+}
+
+func TestAllocator_SkipsReserved(t *testing.T) {
+	policy, err := alias.NewPolicy(alias.PolicyOptions{Reserved: []string{"ab"}})
+	require.NoError(t, err)
+
+	a := alias.New(slogdiscard.NewDiscardLogger(), alias.Options{
+		Generator:   &fixedGenerator{aliases: []string{"AB", "Cd"}},
+		Length:      2,
+		MaxAttempts: 1,
+		Policy:      policy,
+	})
+
+	generated, _, err := a.Generate(1)
+	require.NoError(t, err)
+	require.Equal(t, "cd", generated)
+}
+
+type fixedGenerator struct {
+	aliases []string
+}
+
+func (g *fixedGenerator) Generate(int) (string, error) {
+	a := g.aliases[0]
+	g.aliases = g.aliases[1:]
+	return a, nil
+}
@@ -0,0 +1,42 @@
+package alias
+
+import (
+	"fmt"
+
+	"url-shortener/internal/lib/random"
+)
+
+const (
+	consonants = "bdfghjkmnprstvz"
+	vowels     = "aeiou"
+)
+
+// Pronounceable generates lowercase aliases of alternating consonants and
+// vowels, such as "dabokite", that are easy to read aloud and remember.
+// Its keyspace is much smaller than base62, so longer aliases are advisable.
+type Pronounceable struct{}
+
+func (Pronounceable) Generate(length int) (string, error) {
+	const op = "lib.alias.Pronounceable.Generate"
+
+	cons, err := random.NewString(consonants, (length+1)/2)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	vows, err := random.NewString(vowels, length/2)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	b := make([]byte, length)
+	for i := range b {
+		if i%2 == 0 {
+			b[i] = cons[i/2]
+		} else {
+			b[i] = vows[i/2]
+		}
+	}
+
+	return string(b), nil
+}
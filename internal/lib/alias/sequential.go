@@ -0,0 +1,75 @@
+package alias
+
+import (
+	"hash/fnv"
+	"math/big"
+	"math/rand"
+	"sync/atomic"
+
+	"url-shortener/internal/lib/random"
+)
+
+// multiplier is a prime, so it is coprime with every power of 62 and
+// multiplying by it modulo 62^length permutes the keyspace.
+var multiplier = new(big.Int).SetUint64(1<<61 - 1)
+
+// Sequential encodes an incrementing counter as a fixed-length base62 alias.
+// The counter is scrambled by a keyspace permutation, mixed across all
+// positions and written with an alphabet shuffled by a salt, so consecutive
+// aliases look unrelated, yet the first 62^length aliases never repeat.
+type Sequential struct {
+	alphabet []byte
+	counter  atomic.Uint64
+}
+
+func NewSequential(salt string, start uint64) *Sequential {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(salt))
+	seed := h.Sum64()
+
+	alphabet := []byte(random.Base62)
+	rnd := rand.New(rand.NewSource(int64(seed)))
+	rnd.Shuffle(len(alphabet), func(i, j int) {
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	})
+
+	s := &Sequential{alphabet: alphabet}
+	s.counter.Store(start)
+
+	return s
+}
+
+func (s *Sequential) Generate(length int) (string, error) {
+	return s.encode(s.counter.Add(1)-1, length), nil
+}
+
+func (s *Sequential) encode(n uint64, length int) string {
+	base := big.NewInt(int64(len(s.alphabet)))
+	space := new(big.Int).Exp(base, big.NewInt(int64(length)), nil)
+
+	v := new(big.Int).SetUint64(n)
+	v.Mul(v, multiplier).Mod(v, space)
+
+	digits := make([]int, length)
+	digit := new(big.Int)
+	for i := range digits {
+		v.DivMod(v, base, digit)
+		digits[i] = int(digit.Int64())
+	}
+
+	// Two chained passes make every position depend on every digit. Both
+	// are reversible, so distinct counters still map to distinct aliases.
+	for i := 1; i < length; i++ {
+		digits[i] = (digits[i] + digits[i-1] + i) % len(s.alphabet)
+	}
+	for i := length - 2; i >= 0; i-- {
+		digits[i] = (digits[i] + digits[i+1]) % len(s.alphabet)
+	}
+
+	b := make([]byte, length)
+	for i, d := range digits {
+		b[i] = s.alphabet[d]
+	}
+
+	return string(b)
+}
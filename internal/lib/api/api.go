@@ -10,6 +10,8 @@ var (
 	ErrInvalidStatusCode = errors.New("invalid status code")
 )
 
+// GetRedirect requests url without following redirects and returns the
+// Location of the 3xx response.
 func GetRedirect(url string) (string, error) {
 	const op = "api.GetRedirect"
 
@@ -25,7 +27,7 @@ func GetRedirect(url string) (string, error) {
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusFound {
+	if resp.StatusCode < 300 || resp.StatusCode > 399 {
 		return "", fmt.Errorf("%s: %w: %d", op, ErrInvalidStatusCode, resp.StatusCode)
 	}
 
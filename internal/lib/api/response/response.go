@@ -2,6 +2,7 @@ package response
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -30,6 +31,24 @@ func Error(msg string) Response {
 	}
 }
 
+// PolicyViolation is an error response naming the policy rule a request
+// was rejected by.
+type PolicyViolation struct {
+	Response
+	Rule string `json:"rule"`
+}
+
+func Violation(msg, rule string) PolicyViolation {
+	return PolicyViolation{
+		Response: Error(msg),
+		Rule:     rule,
+	}
+}
+
+// unescapeParam restores the separators of the tag syntax that tag parameters
+// spell as hex codes, such as the charset of alias.Policy.
+var unescapeParam = strings.NewReplacer("0x2C", ",", "0x7C", "|")
+
 func ValidationError(errs validator.ValidationErrors) Response {
 	var errMsgs []string
 
@@ -39,6 +58,18 @@ func ValidationError(errs validator.ValidationErrors) Response {
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is a required field", err.Field()))
 		case "url":
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not a valid URL", err.Field()))
+		case "oneof":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be one of: %s", err.Field(), strings.ReplaceAll(err.Param(), " ", ", ")))
+		case "min":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be at least %s", err.Field(), lengthOrValue(err)))
+		case "max":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be at most %s", err.Field(), lengthOrValue(err)))
+		case "alias_charset":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s may only contain characters [%s]", err.Field(), unescapeParam.Replace(err.Param())))
+		case "alias_reserved":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is reserved", err.Field()))
+		case "excluded_with":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s cannot be used together with %s", err.Field(), strings.ReplaceAll(err.Param(), " ", " or ")))
 		default:
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not valid", err.Field()))
 		}
@@ -49,3 +80,16 @@ func ValidationError(errs validator.ValidationErrors) Response {
 		Error:  strings.Join(errMsgs, ", "),
 	}
 }
+
+// lengthOrValue renders the parameter of a min or max check, which bounds the
+// length of strings and slices and the value of numbers.
+func lengthOrValue(err validator.FieldError) string {
+	switch err.Kind() {
+	case reflect.String:
+		return err.Param() + " characters long"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return err.Param() + " items"
+	default:
+		return err.Param()
+	}
+}
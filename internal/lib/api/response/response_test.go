@@ -0,0 +1,29 @@
+package response_test
+
+import (
+	"testing"
+
+	"url-shortener/internal/lib/api/response"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/require"
+)
+
+// fieldError is a validation error of the field Alias failing tag.
+type fieldError struct {
+	validator.FieldError
+	tag   string
+	param string
+}
+
+func (e fieldError) ActualTag() string { return e.tag }
+func (e fieldError) Field() string     { return "Alias" }
+func (e fieldError) Param() string     { return e.param }
+
+func TestValidationError_CharsetParam(t *testing.T) {
+	resp := response.ValidationError(validator.ValidationErrors{
+		fieldError{tag: "alias_charset", param: "a-z0x2C0x7C"},
+	})
+
+	require.Equal(t, "field Alias may only contain characters [a-z,|]", resp.Error)
+}
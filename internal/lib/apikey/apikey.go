@@ -0,0 +1,53 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"url-shortener/internal/lib/random"
+)
+
+const (
+	// TokenPrefix starts every issued token, so leaked keys are easy to spot
+	// by secret scanners.
+	TokenPrefix = "usk_"
+
+	secretLength  = 40
+	displayLength = len(TokenPrefix) + 8
+)
+
+// Generate returns a new random token. 40 base62 characters carry about 238
+// bits of entropy, so a plain SHA-256 is enough to store it.
+func Generate() (string, error) {
+	const op = "lib.apikey.Generate"
+
+	secret, err := random.NewRandomString(secretLength)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return TokenPrefix + secret, nil
+}
+
+// Hash returns the hex SHA-256 of a token, the form tokens are stored and
+// looked up in.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Prefix returns the start of a token that is safe to show in listings.
+func Prefix(token string) string {
+	if len(token) <= displayLength {
+		return token
+	}
+	return token[:displayLength]
+}
+
+// Valid reports whether token has the shape of an issued token, which spares
+// a storage lookup for obviously wrong ones.
+func Valid(token string) bool {
+	return strings.HasPrefix(token, TokenPrefix) && len(token) == len(TokenPrefix)+secretLength
+}
@@ -0,0 +1,35 @@
+package apikey_test
+
+import (
+	"strings"
+	"testing"
+
+	"url-shortener/internal/lib/apikey"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	a, err := apikey.Generate()
+	require.NoError(t, err)
+
+	b, err := apikey.Generate()
+	require.NoError(t, err)
+
+	require.NotEqual(t, a, b)
+	require.True(t, strings.HasPrefix(a, apikey.TokenPrefix))
+	require.True(t, apikey.Valid(a))
+
+	require.Len(t, apikey.Hash(a), 64)
+	require.Equal(t, apikey.Hash(a), apikey.Hash(a))
+	require.NotEqual(t, apikey.Hash(a), apikey.Hash(b))
+
+	require.Equal(t, a[:12], apikey.Prefix(a))
+	require.Equal(t, "usk_", apikey.Prefix("usk_"))
+}
+
+func TestValid(t *testing.T) {
+	for _, token := range []string{"", "usk_", "usk_short", "key_" + strings.Repeat("a", 40), "usk_" + strings.Repeat("a", 41)} {
+		require.False(t, apikey.Valid(token), token)
+	}
+}
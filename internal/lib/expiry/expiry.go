@@ -0,0 +1,32 @@
+package expiry
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidTTL    = errors.New("field TTL must be a positive duration")
+	ErrExpiresInPast = errors.New("field ExpiresAt must be in the future")
+)
+
+// Resolve returns the absolute expiry time requested either as an absolute
+// time or as a TTL relative to now, or nil when neither is set.
+func Resolve(expiresAt *time.Time, ttl string, now time.Time) (*time.Time, error) {
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidTTL
+		}
+
+		resolved := now.Add(d)
+
+		return &resolved, nil
+	}
+
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, ErrExpiresInPast
+	}
+
+	return expiresAt, nil
+}
@@ -0,0 +1,104 @@
+package passthrough
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidPath is returned for forwarded paths with "." or ".." segments,
+// which could climb out of the destination path.
+var ErrInvalidPath = errors.New("invalid path")
+
+// Forward appends the escaped path and merges the raw query of a visited
+// short link into destination. Both keep the encoding the visitor used.
+//
+// Query parameters already present in destination take precedence, visitor
+// parameters with the same name are dropped, as are those named in drop.
+// The other visitor parameters are appended after the destination's own.
+func Forward(destination, escapedPath, rawQuery string, drop ...string) (string, error) {
+	const op = "lib.passthrough.Forward"
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if escapedPath != "" && escapedPath != "/" {
+		if err := checkSegments(escapedPath); err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		escaped := strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.TrimPrefix(escapedPath, "/")
+
+		path, err := url.PathUnescape(escaped)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		u.Path, u.RawPath = path, escaped
+	}
+
+	if rawQuery != "" {
+		u.RawQuery = mergeQuery(u.RawQuery, rawQuery, drop)
+	}
+
+	return u.String(), nil
+}
+
+func checkSegments(escapedPath string) error {
+	for _, segment := range strings.Split(escapedPath, "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return err
+		}
+
+		if unescaped == "." || unescaped == ".." {
+			return ErrInvalidPath
+		}
+	}
+
+	return nil
+}
+
+func mergeQuery(destQuery, visitQuery string, drop []string) string {
+	skip := make(map[string]bool, len(drop))
+	for _, key := range drop {
+		skip[key] = true
+	}
+
+	params := splitQuery(destQuery)
+	for _, p := range params {
+		skip[queryKey(p)] = true
+	}
+
+	for _, p := range splitQuery(visitQuery) {
+		if !skip[queryKey(p)] {
+			params = append(params, p)
+		}
+	}
+
+	return strings.Join(params, "&")
+}
+
+func splitQuery(rawQuery string) []string {
+	var params []string
+
+	for _, p := range strings.Split(rawQuery, "&") {
+		if p != "" {
+			params = append(params, p)
+		}
+	}
+
+	return params
+}
+
+func queryKey(param string) string {
+	key, _, _ := strings.Cut(param, "=")
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		return unescaped
+	}
+
+	return key
+}
@@ -0,0 +1,125 @@
+package passthrough_test
+
+import (
+	"testing"
+
+	"url-shortener/internal/lib/passthrough"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForward(t *testing.T) {
+	cases := []struct {
+		name  string
+		dest  string
+		path  string
+		query string
+		drop  []string
+		want  string
+	}{
+		{
+			name: "Nothing forwarded",
+			dest: "https://example.com/docs?v=1",
+			want: "https://example.com/docs?v=1",
+		},
+		{
+			name:  "Query added",
+			dest:  "https://example.com/docs",
+			query: "ref=newsletter",
+			want:  "https://example.com/docs?ref=newsletter",
+		},
+		{
+			name:  "Query appended after the destination's own",
+			dest:  "https://example.com/docs?v=1",
+			query: "ref=newsletter&page=2",
+			want:  "https://example.com/docs?v=1&ref=newsletter&page=2",
+		},
+		{
+			name:  "Destination parameters take precedence",
+			dest:  "https://example.com/docs?ref=partner&v=1",
+			query: "ref=newsletter&v=2&v=3&x=1",
+			want:  "https://example.com/docs?ref=partner&v=1&x=1",
+		},
+		{
+			name:  "Precedence compares unescaped names",
+			dest:  "https://example.com/?a%20b=1",
+			query: "a+b=2&c=3",
+			want:  "https://example.com/?a%20b=1&c=3",
+		},
+		{
+			name:  "Repeated visitor parameters kept",
+			dest:  "https://example.com/",
+			query: "tag=a&tag=b",
+			want:  "https://example.com/?tag=a&tag=b",
+		},
+		{
+			name:  "Dropped parameters",
+			dest:  "https://example.com/",
+			query: "password=secret&ref=x",
+			drop:  []string{"password"},
+			want:  "https://example.com/?ref=x",
+		},
+		{
+			name:  "Query encoding preserved",
+			dest:  "https://example.com/search",
+			query: "q=a+b%26c&path=%2Fx",
+			want:  "https://example.com/search?q=a+b%26c&path=%2Fx",
+		},
+		{
+			name: "Path appended",
+			dest: "https://example.com/docs",
+			path: "/extra/path",
+			want: "https://example.com/docs/extra/path",
+		},
+		{
+			name: "Path appended to trailing slash",
+			dest: "https://example.com/docs/",
+			path: "/extra",
+			want: "https://example.com/docs/extra",
+		},
+		{
+			name: "Path appended to host",
+			dest: "https://example.com",
+			path: "/extra",
+			want: "https://example.com/extra",
+		},
+		{
+			name: "Bare slash ignored",
+			dest: "https://example.com/docs",
+			path: "/",
+			want: "https://example.com/docs",
+		},
+		{
+			name: "Path encoding preserved",
+			dest: "https://example.com/a%2Fb",
+			path: "/c%2Fd/e%20f",
+			want: "https://example.com/a%2Fb/c%2Fd/e%20f",
+		},
+		{
+			name:  "Path and query with fragment",
+			dest:  "https://example.com/docs?v=1#intro",
+			path:  "/guide",
+			query: "ref=x",
+			want:  "https://example.com/docs/guide?v=1&ref=x#intro",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := passthrough.Forward(tc.dest, tc.path, tc.query, tc.drop...)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestForward_InvalidPath(t *testing.T) {
+	for _, path := range []string{"/../admin", "/a/./b", "/%2e%2e/admin", "/a/%2E", "/%zz"} {
+		_, err := passthrough.Forward("https://example.com/public", path, "")
+		require.Error(t, err, path)
+	}
+}
@@ -2,23 +2,34 @@ package random
 
 import (
 	"crypto/rand"
+	"fmt"
 	"math/big"
 )
 
-func NewRandomString(size int) string {
-	chars := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
-		"abcdefghijklmnopqrstuvwxyz" +
-		"0123456789")
+// Base62 is the alphabet of NewRandomString.
+const Base62 = "ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"abcdefghijklmnopqrstuvwxyz" +
+	"0123456789"
+
+func NewRandomString(size int) (string, error) {
+	return NewString(Base62, size)
+}
+
+// NewString returns a string of size characters picked uniformly from alphabet.
+func NewString(alphabet string, size int) (string, error) {
+	const op = "lib.random.NewString"
+
+	chars := []rune(alphabet)
 
 	b := make([]rune, size)
 	for i := range b {
 		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
 		if err != nil {
-			return ""
+			return "", fmt.Errorf("%s: %w", op, err)
 		}
 
 		b[i] = chars[num.Int64()]
 	}
 
-	return string(b)
+	return string(b), nil
 }
@@ -0,0 +1,81 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter counts attempts per key until one succeeds. Once a key reaches the
+// maximum number of failures it is locked until the window that started with
+// its first attempt has passed.
+type Limiter struct {
+	mu        sync.Mutex
+	max       int
+	window    time.Duration
+	entries   map[string]entry
+	lastSweep time.Time
+}
+
+type entry struct {
+	failures int
+	start    time.Time
+}
+
+func New(maxFailures int, window time.Duration) *Limiter {
+	return &Limiter{
+		max:       maxFailures,
+		window:    window,
+		entries:   make(map[string]entry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow reports whether another attempt may be made for key, and otherwise
+// how long the caller has to wait. Allowed attempts count as failures right
+// away, so concurrent attempts cannot all pass before the first one fails;
+// callers Reset the key once an attempt succeeds.
+func (l *Limiter) Allow(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || !now.Before(e.start.Add(l.window)) {
+		e = entry{start: now}
+	}
+
+	if e.failures >= l.max {
+		return e.start.Add(l.window).Sub(now), false
+	}
+
+	e.failures++
+	l.entries[key] = e
+
+	return 0, true
+}
+
+// Reset forgets the attempts recorded for key.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+// sweep drops keys whose window has passed, at most once per window, so keys
+// that are never tried again do not accumulate.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+
+	for key, e := range l.entries {
+		if !now.Before(e.start.Add(l.window)) {
+			delete(l.entries, key)
+		}
+	}
+
+	l.lastSweep = now
+}
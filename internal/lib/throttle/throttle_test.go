@@ -0,0 +1,76 @@
+package throttle_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"url-shortener/internal/lib/throttle"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := throttle.New(3, 100*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		_, ok := l.Allow("a")
+		require.True(t, ok)
+	}
+
+	wait, ok := l.Allow("a")
+	require.False(t, ok)
+	require.Positive(t, wait)
+	require.LessOrEqual(t, wait, 100*time.Millisecond)
+
+	// Other keys are counted separately.
+	_, ok = l.Allow("b")
+	require.True(t, ok)
+
+	require.Eventually(t, func() bool {
+		_, ok := l.Allow("a")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	// The lock expired together with the failures that caused it.
+	_, ok = l.Allow("a")
+	require.True(t, ok)
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	l := throttle.New(2, time.Hour)
+
+	l.Allow("a")
+	l.Allow("a")
+
+	_, ok := l.Allow("a")
+	require.False(t, ok)
+
+	l.Reset("a")
+
+	_, ok = l.Allow("a")
+	require.True(t, ok)
+}
+
+// TestLimiter_Concurrent checks attempts made before any of them failed are
+// still limited.
+func TestLimiter_Concurrent(t *testing.T) {
+	l := throttle.New(3, time.Hour)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := l.Allow("a"); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(3), allowed.Load())
+}
@@ -0,0 +1,166 @@
+package urlnorm
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+type Options struct {
+	// SortQuery orders query parameters by name, keeping the relative order
+	// of repeated parameters.
+	SortQuery bool
+	// DropParams lists query parameters to remove, matched case-insensitively.
+	// A trailing "*" matches by prefix, e.g. "utm_*".
+	DropParams []string
+}
+
+// Normalizer canonicalizes destination URLs so that equivalent spellings of
+// the same address compare equal.
+type Normalizer struct {
+	opts Options
+}
+
+func New(opts Options) *Normalizer {
+	return &Normalizer{opts: opts}
+}
+
+// Normalize lowercases the scheme and host, strips the default port, resolves
+// dot segments and applies the configured query rules. The original encoding
+// of the path and query is preserved.
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	const op = "lib.urlnorm.Normalize"
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+
+	if port := u.Port(); port != "" && port == defaultPorts[u.Scheme] {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+
+	if u.Opaque == "" {
+		escaped := removeDotSegments(u.EscapedPath())
+		if escaped == "" && u.Host != "" {
+			escaped = "/"
+		}
+
+		path, err := url.PathUnescape(escaped)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		u.Path, u.RawPath = path, escaped
+	}
+
+	u.RawQuery = n.query(u.RawQuery)
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+func (n *Normalizer) query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	type param struct {
+		key string
+		raw string
+	}
+
+	var params []param
+
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(raw, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+
+		if n.dropped(key) {
+			continue
+		}
+
+		params = append(params, param{key: key, raw: raw})
+	}
+
+	if n.opts.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			return params[i].key < params[j].key
+		})
+	}
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.raw
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func (n *Normalizer) dropped(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, pattern := range n.opts.DropParams {
+		pattern = strings.ToLower(pattern)
+
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+			continue
+		}
+
+		if key == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeDotSegments resolves "." and ".." segments of an absolute path as
+// described in RFC 3986, section 5.2.4.
+func removeDotSegments(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	out := make([]string, 0, len(segments))
+	last := len(segments) - 1
+
+	for i, segment := range segments {
+		switch segment {
+		case ".":
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+			continue
+		}
+
+		// A trailing dot segment still denotes a directory.
+		if i == last {
+			out = append(out, "")
+		}
+	}
+
+	return strings.Join(out, "/")
+}
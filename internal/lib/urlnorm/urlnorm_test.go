@@ -0,0 +1,133 @@
+package urlnorm_test
+
+import (
+	"testing"
+
+	"url-shortener/internal/lib/urlnorm"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name string
+		opts urlnorm.Options
+		url  string
+		want string
+	}{
+		{
+			name: "Already canonical",
+			url:  "https://example.com/path?q=1",
+			want: "https://example.com/path?q=1",
+		},
+		{
+			name: "Empty path",
+			url:  "https://example.com",
+			want: "https://example.com/",
+		},
+		{
+			name: "Scheme and host case",
+			url:  "HTTPS://Example.COM/Path",
+			want: "https://example.com/Path",
+		},
+		{
+			name: "Default HTTPS port",
+			url:  "https://example.com:443/",
+			want: "https://example.com/",
+		},
+		{
+			name: "Default HTTP port",
+			url:  "http://example.com:80/a",
+			want: "http://example.com/a",
+		},
+		{
+			name: "Non-default port kept",
+			url:  "https://example.com:8443/a",
+			want: "https://example.com:8443/a",
+		},
+		{
+			name: "Dot segments",
+			url:  "https://example.com/a/./b/../c",
+			want: "https://example.com/a/c",
+		},
+		{
+			name: "Trailing dot segment",
+			url:  "https://example.com/a/b/..",
+			want: "https://example.com/a/",
+		},
+		{
+			name: "Dot segments above root",
+			url:  "https://example.com/../../a",
+			want: "https://example.com/a",
+		},
+		{
+			name: "Path encoding preserved",
+			url:  "https://example.com/a%2Fb/c%20d",
+			want: "https://example.com/a%2Fb/c%20d",
+		},
+		{
+			name: "Query order kept by default",
+			url:  "https://example.com/?b=2&a=1",
+			want: "https://example.com/?b=2&a=1",
+		},
+		{
+			name: "Sorted query",
+			opts: urlnorm.Options{SortQuery: true},
+			url:  "https://example.com/?b=2&a=1&b=1",
+			want: "https://example.com/?a=1&b=2&b=1",
+		},
+		{
+			name: "Tracking parameters dropped",
+			opts: urlnorm.Options{DropParams: []string{"utm_*", "fbclid"}},
+			url:  "https://example.com/?id=7&utm_source=mail&UTM_Medium=x&fbclid=abc&utmost=1",
+			want: "https://example.com/?id=7&utmost=1",
+		},
+		{
+			name: "All parameters dropped",
+			opts: urlnorm.Options{DropParams: []string{"utm_*"}},
+			url:  "https://example.com/a?utm_source=mail",
+			want: "https://example.com/a",
+		},
+		{
+			name: "Query encoding preserved",
+			opts: urlnorm.Options{SortQuery: true},
+			url:  "https://example.com/?q=a+b&p=%2F",
+			want: "https://example.com/?p=%2F&q=a+b",
+		},
+		{
+			name: "Fragment kept",
+			url:  "https://Example.com:443#top",
+			want: "https://example.com/#top",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := urlnorm.New(tc.opts).Normalize(tc.url)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNormalize_Equivalent(t *testing.T) {
+	n := urlnorm.New(urlnorm.Options{})
+
+	want, err := n.Normalize("https://example.com")
+	require.NoError(t, err)
+
+	for _, u := range []string{"https://Example.com/", "https://example.com:443/", "HTTPS://EXAMPLE.COM/./"} {
+		got, err := n.Normalize(u)
+		require.NoError(t, err)
+		require.Equal(t, want, got, u)
+	}
+}
+
+func TestNormalize_Invalid(t *testing.T) {
+	_, err := urlnorm.New(urlnorm.Options{}).Normalize("https://example.com/%zz")
+	require.Error(t, err)
+}
@@ -0,0 +1,299 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/internal/lib/logger/sl"
+)
+
+// missReloadInterval limits how often a token signed with an unknown key
+// triggers a reload, so forged key ids cannot hammer the identity provider.
+const missReloadInterval = 30 * time.Second
+
+// maxJWKSSize bounds the JWKS documents read from a file or URL.
+const maxJWKSSize = 1 << 20
+
+var errUnknownKey = errors.New("unknown signing key")
+
+// KeySet holds the public keys tokens are signed with, read from a JWKS file
+// or URL. Keys are reloaded while Run is active and whenever a token names a
+// key the set does not know yet, which picks up rotated keys.
+type KeySet struct {
+	log    *slog.Logger
+	file   string
+	url    string
+	client *http.Client
+
+	keys atomic.Pointer[[]jwk]
+
+	mu       sync.Mutex
+	missedAt time.Time
+}
+
+type jwk struct {
+	id  string
+	alg string
+	key crypto.PublicKey
+}
+
+// NewKeySet loads the keys from file, or from url when file is empty.
+func NewKeySet(ctx context.Context, log *slog.Logger, file, url string, client *http.Client) (*KeySet, error) {
+	const op = "oidc.NewKeySet"
+
+	if file == "" && url == "" {
+		return nil, fmt.Errorf("%s: neither a JWKS file nor URL configured", op)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	s := &KeySet{log: log, file: file, url: url, client: client}
+
+	if err := s.Reload(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// Reload reads the JWKS and swaps the keys in. On error the current keys stay
+// in effect.
+func (s *KeySet) Reload(ctx context.Context) error {
+	const op = "oidc.KeySet.Reload"
+
+	data, err := s.read(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.keys.Store(&keys)
+
+	return nil
+}
+
+// Run reloads the keys every interval until ctx is done.
+func (s *KeySet) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.Reload(ctx); err != nil {
+			s.log.Error("failed to reload jwks, keeping previous keys", sl.Err(err))
+		}
+	}
+}
+
+// Key returns the key with id kid usable for alg. Tokens without a key id
+// are accepted when the set holds a single usable key.
+func (s *KeySet) Key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	if key, ok := s.find(kid, alg); ok {
+		return key, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another request may have reloaded the keys while this one waited.
+	if key, ok := s.find(kid, alg); ok {
+		return key, nil
+	}
+
+	if time.Since(s.missedAt) < missReloadInterval {
+		return nil, errUnknownKey
+	}
+	s.missedAt = time.Now()
+
+	if err := s.Reload(ctx); err != nil {
+		s.log.Error("failed to reload jwks for unknown key", slog.String("kid", kid), sl.Err(err))
+		return nil, errUnknownKey
+	}
+
+	if key, ok := s.find(kid, alg); ok {
+		return key, nil
+	}
+
+	return nil, errUnknownKey
+}
+
+func (s *KeySet) find(kid, alg string) (crypto.PublicKey, bool) {
+	var match crypto.PublicKey
+	matches := 0
+
+	for _, k := range *s.keys.Load() {
+		if (kid != "" && k.id != kid) || !usable(k, alg) {
+			continue
+		}
+
+		match = k.key
+		matches++
+	}
+
+	if matches != 1 {
+		return nil, false
+	}
+
+	return match, true
+}
+
+// usable reports whether k verifies signatures made with alg.
+func usable(k jwk, alg string) bool {
+	if k.alg != "" && k.alg != alg {
+		return false
+	}
+
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256"
+	case *ecdsa.PublicKey:
+		return alg == "ES256" && key.Curve == elliptic.P256()
+	default:
+		return false
+	}
+}
+
+func (s *KeySet) read(ctx context.Context) ([]byte, error) {
+	if s.file != "" {
+		return os.ReadFile(s.file)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: unexpected status %d", s.url, resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// parseJWKS returns the RSA and P-256 signing keys of a JWKS document. Keys of
+// other types or for encryption are skipped.
+func parseJWKS(data []byte) ([]jwk, error) {
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+
+	keys := make([]jwk, 0, len(doc.Keys))
+
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+
+		switch {
+		case k.Kty == "RSA":
+			n, err := decodeInt(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: n: %w", k.Kid, err)
+			}
+			e, err := decodeInt(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: e: %w", k.Kid, err)
+			}
+			if !e.IsInt64() || e.Int64() < 2 || e.Int64() > 1<<31-1 {
+				return nil, fmt.Errorf("key %q: invalid exponent", k.Kid)
+			}
+
+			key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, err := decodeInt(k.X)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: x: %w", k.Kid, err)
+			}
+			y, err := decodeInt(k.Y)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: y: %w", k.Kid, err)
+			}
+			if !onP256(x, y) {
+				return nil, fmt.Errorf("key %q: point is not on P-256", k.Kid)
+			}
+
+			key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		default:
+			continue
+		}
+
+		keys = append(keys, jwk{id: k.Kid, alg: k.Alg, key: key})
+	}
+
+	return keys, nil
+}
+
+// onP256 reports whether (x, y) is a valid P-256 public key.
+func onP256(x, y *big.Int) bool {
+	if x.BitLen() > 256 || y.BitLen() > 256 {
+		return false
+	}
+
+	point := make([]byte, 65)
+	point[0] = 4
+	x.FillBytes(point[1:33])
+	y.FillBytes(point[33:])
+
+	_, err := ecdh.P256().NewPublicKey(point)
+	return err == nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,150 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/rbac"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned by Verify for tokens that are malformed, badly
+// signed, expired or issued for someone else.
+var ErrInvalidToken = errors.New("invalid token")
+
+type Options struct {
+	// Issuer and Audience are checked against the iss and aud claims when set.
+	Issuer   string
+	Audience string
+	// UserClaim names the claim holding the user name, sub when empty.
+	UserClaim string
+	// GroupsClaim names the claim listing the groups of the user, groups
+	// when empty.
+	GroupsClaim string
+	// Roles maps groups to roles. Tokens get the most privileged role of
+	// their groups, or DefaultRole when none of them is mapped.
+	Roles       map[string]rbac.Role
+	DefaultRole rbac.Role
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration
+}
+
+// Identity is the user a verified token was issued to.
+type Identity struct {
+	// Issuer and Subject are the iss and sub claims, which together identify
+	// the account. Subject is read from UserClaim.
+	Issuer  string
+	Subject string
+	Groups  []string
+	// Role is empty when no role applies to the groups of the token.
+	Role rbac.Role
+}
+
+// Verifier validates RS256 and ES256 signed JWTs against a KeySet.
+type Verifier struct {
+	keys   *KeySet
+	opts   Options
+	parser *jwt.Parser
+}
+
+func NewVerifier(keys *KeySet, opts Options) *Verifier {
+	if opts.UserClaim == "" {
+		opts.UserClaim = "sub"
+	}
+	if opts.GroupsClaim == "" {
+		opts.GroupsClaim = "groups"
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(opts.Leeway),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+
+	return &Verifier{
+		keys:   keys,
+		opts:   opts,
+		parser: jwt.NewParser(parserOpts...),
+	}
+}
+
+// Verify checks the signature and claims of token and returns whom it was
+// issued to. Every failure wraps ErrInvalidToken.
+func (v *Verifier) Verify(ctx context.Context, token string) (Identity, error) {
+	const op = "oidc.Verifier.Verify"
+
+	claims := jwt.MapClaims{}
+
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid, t.Method.Alg())
+	})
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	subject, _ := claims[v.opts.UserClaim].(string)
+	if subject == "" {
+		return Identity{}, fmt.Errorf("%s: %w: missing %s claim", op, ErrInvalidToken, v.opts.UserClaim)
+	}
+
+	groups, err := stringList(claims[v.opts.GroupsClaim])
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w: %s claim: %w", op, ErrInvalidToken, v.opts.GroupsClaim, err)
+	}
+
+	issuer, _ := claims["iss"].(string)
+
+	return Identity{
+		Issuer:  issuer,
+		Subject: subject,
+		Groups:  groups,
+		Role:    v.role(groups),
+	}, nil
+}
+
+// role returns the most privileged role mapped to any of groups.
+func (v *Verifier) role(groups []string) rbac.Role {
+	for i := len(rbac.Roles) - 1; i >= 0; i-- {
+		for _, group := range groups {
+			if v.opts.Roles[group] == rbac.Roles[i] {
+				return rbac.Roles[i]
+			}
+		}
+	}
+
+	return v.opts.DefaultRole
+}
+
+// stringList accepts the groups claim as a list of strings or, as some
+// providers send single groups, a plain string.
+func stringList(claim any) ([]string, error) {
+	switch claim := claim.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{claim}, nil
+	case []any:
+		list := make([]string, 0, len(claim))
+		for _, item := range claim {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("not a list of strings")
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	default:
+		return nil, errors.New("not a list of strings")
+	}
+}
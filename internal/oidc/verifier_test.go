@@ -0,0 +1,392 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/oidc"
+	"url-shortener/internal/rbac"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	issuer   = "https://sso.example.com"
+	audience = "url-shortener"
+)
+
+var (
+	rsaKey = mustRSAKey()
+	ecKey  = mustECKey()
+)
+
+// jwksServer serves a JWKS document like the identity provider would.
+type jwksServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	keys     []map[string]string
+	requests atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys ...map[string]string) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": s.keys})
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = keys
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	server := newJWKSServer(t, rsaJWK("rsa-1", rsaKey), ecJWK("ec-1", ecKey))
+
+	keys, err := oidc.NewKeySet(context.Background(), slogdiscard.NewDiscardLogger(), "", server.URL, nil)
+	require.NoError(t, err)
+
+	verifier := oidc.NewVerifier(keys, oidc.Options{
+		Issuer:   issuer,
+		Audience: audience,
+		Roles: map[string]rbac.Role{
+			"shortener-admins":  rbac.RoleAdmin,
+			"shortener-editors": rbac.RoleEditor,
+		},
+		DefaultRole: rbac.RoleViewer,
+	})
+
+	otherRSA := mustRSAKey()
+
+	cases := []struct {
+		name    string
+		token   string
+		subject string
+		role    rbac.Role
+		invalid bool
+	}{
+		{
+			name:    "RS256",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(nil)),
+			subject: "pedro",
+			role:    rbac.RoleEditor,
+		},
+		{
+			name:    "ES256",
+			token:   sign(t, jwt.SigningMethodES256, "ec-1", ecKey, claims(nil)),
+			subject: "pedro",
+			role:    rbac.RoleEditor,
+		},
+		{
+			name:    "Most privileged group wins",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"groups": []string{"staff", "shortener-editors", "shortener-admins"}})),
+			subject: "pedro",
+			role:    rbac.RoleAdmin,
+		},
+		{
+			name:    "Unmapped groups get the default role",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"groups": []string{"staff"}})),
+			subject: "pedro",
+			role:    rbac.RoleViewer,
+		},
+		{
+			name:    "Single group as a string",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"groups": "shortener-admins"})),
+			subject: "pedro",
+			role:    rbac.RoleAdmin,
+		},
+		{
+			name:    "Expired",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+			invalid: true,
+		},
+		{
+			name:    "Without expiry",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"exp": nil})),
+			invalid: true,
+		},
+		{
+			name:    "Not yet valid",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()})),
+			invalid: true,
+		},
+		{
+			name:    "Wrong issuer",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"iss": "https://evil.example.com"})),
+			invalid: true,
+		},
+		{
+			name:    "Wrong audience",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"aud": "another-app"})),
+			invalid: true,
+		},
+		{
+			name:    "Missing subject",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"sub": nil})),
+			invalid: true,
+		},
+		{
+			name:    "Malformed groups",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"groups": []int{1}})),
+			invalid: true,
+		},
+		{
+			name:    "Signed with an unknown key",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa-1", otherRSA, claims(nil)),
+			invalid: true,
+		},
+		{
+			name:    "Key of another type",
+			token:   sign(t, jwt.SigningMethodES256, "rsa-1", ecKey, claims(nil)),
+			invalid: true,
+		},
+		{
+			name:    "HMAC with the public key as secret",
+			token:   sign(t, jwt.SigningMethodHS256, "rsa-1", rsaKey.PublicKey.N.Bytes(), claims(nil)),
+			invalid: true,
+		},
+		{
+			name:    "Unsigned",
+			token:   sign(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType, claims(nil)),
+			invalid: true,
+		},
+		{
+			name:    "Garbage",
+			token:   "not.a.token",
+			invalid: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			identity, err := verifier.Verify(context.Background(), tc.token)
+			if tc.invalid {
+				require.ErrorIs(t, err, oidc.ErrInvalidToken)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.subject, identity.Subject)
+			require.Equal(t, tc.role, identity.Role)
+		})
+	}
+}
+
+func TestVerifier_Claims(t *testing.T) {
+	server := newJWKSServer(t, rsaJWK("rsa-1", rsaKey))
+
+	keys, err := oidc.NewKeySet(context.Background(), slogdiscard.NewDiscardLogger(), "", server.URL, nil)
+	require.NoError(t, err)
+
+	verifier := oidc.NewVerifier(keys, oidc.Options{
+		UserClaim:   "preferred_username",
+		GroupsClaim: "roles",
+		Roles:       map[string]rbac.Role{"admins": rbac.RoleAdmin},
+	})
+
+	token := sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{
+		"preferred_username": "maria",
+		"roles":              []string{"admins"},
+	}))
+
+	identity, err := verifier.Verify(context.Background(), token)
+	require.NoError(t, err)
+	require.Equal(t, issuer, identity.Issuer)
+	require.Equal(t, "maria", identity.Subject)
+	require.Equal(t, []string{"admins"}, identity.Groups)
+	require.Equal(t, rbac.RoleAdmin, identity.Role)
+
+	// Without a default role, tokens outside the mapped groups get no role.
+	token = sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"preferred_username": "maria"}))
+
+	identity, err = verifier.Verify(context.Background(), token)
+	require.NoError(t, err)
+	require.Empty(t, identity.Role)
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	server := newJWKSServer(t, rsaJWK("rsa-1", rsaKey))
+
+	keys, err := oidc.NewKeySet(context.Background(), slogdiscard.NewDiscardLogger(), "", server.URL, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, server.requests.Load())
+
+	verifier := oidc.NewVerifier(keys, oidc.Options{})
+
+	rotated := mustRSAKey()
+	server.setKeys(rsaJWK("rsa-1", rsaKey), rsaJWK("rsa-2", rotated))
+
+	// A token signed with the new key makes the set reload.
+	_, err = verifier.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-2", rotated, claims(nil)))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, server.requests.Load())
+
+	// Unknown keys do not reload again right away.
+	_, err = verifier.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-3", mustRSAKey(), claims(nil)))
+	require.ErrorIs(t, err, oidc.ErrInvalidToken)
+	require.EqualValues(t, 2, server.requests.Load())
+}
+
+func TestKeySet_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+
+	data, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		ecJWK("ec-1", ecKey),
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	keys, err := oidc.NewKeySet(context.Background(), slogdiscard.NewDiscardLogger(), path, "", nil)
+	require.NoError(t, err)
+
+	verifier := oidc.NewVerifier(keys, oidc.Options{})
+
+	// Without a key id the only usable key is picked.
+	identity, err := verifier.Verify(context.Background(), sign(t, jwt.SigningMethodES256, "", ecKey, claims(nil)))
+	require.NoError(t, err)
+	require.Equal(t, "pedro", identity.Subject)
+}
+
+func TestNewKeySet_Invalid(t *testing.T) {
+	log := slogdiscard.NewDiscardLogger()
+
+	_, err := oidc.NewKeySet(context.Background(), log, "", "", nil)
+	require.Error(t, err)
+
+	_, err = oidc.NewKeySet(context.Background(), log, filepath.Join(t.TempDir(), "missing.json"), "", nil)
+	require.Error(t, err)
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(notFound.Close)
+
+	_, err = oidc.NewKeySet(context.Background(), log, "", notFound.URL, nil)
+	require.Error(t, err)
+
+	offCurve := ecJWK("ec-1", ecKey)
+	offCurve["y"] = offCurve["x"]
+	server := newJWKSServer(t, offCurve)
+
+	_, err = oidc.NewKeySet(context.Background(), log, "", server.URL, nil)
+	require.Error(t, err)
+}
+
+// claims returns valid claims for pedro, with overrides applied. Nil
+// overrides remove the claim.
+func claims(overrides jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"iss":    issuer,
+		"aud":    audience,
+		"sub":    "pedro",
+		"groups": []string{"shortener-editors"},
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+
+	for name, value := range overrides {
+		if value == nil {
+			delete(c, name)
+			continue
+		}
+		c[name] = value
+	}
+
+	return c
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	return signed
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   encode(key.N),
+		"e":   encode(big.NewInt(int64(key.E))),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	public, err := key.PublicKey.ECDH()
+	if err != nil {
+		panic(err)
+	}
+
+	// The uncompressed point is 0x04 followed by x and y.
+	point := public.Bytes()
+
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(point[1:33]),
+		"y":   base64.RawURLEncoding.EncodeToString(point[33:]),
+	}
+}
+
+func encode(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func mustRSAKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func mustECKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
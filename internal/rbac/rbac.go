@@ -0,0 +1,81 @@
+package rbac
+
+import (
+	"fmt"
+)
+
+// Role is the set of permissions a user or API key is granted.
+type Role string
+
+const (
+	// RoleViewer may read its own links and their statistics.
+	RoleViewer Role = "viewer"
+	// RoleEditor may additionally create links and change its own ones.
+	RoleEditor Role = "editor"
+	// RoleAdmin may additionally change every link, manage users and API
+	// keys and read the audit log.
+	RoleAdmin Role = "admin"
+)
+
+// Permission is an operation a route requires.
+type Permission string
+
+const (
+	PermLinksRead  Permission = "links:read"
+	PermLinksWrite Permission = "links:write"
+	// PermLinksAny lifts the restriction of link changes to the own links.
+	PermLinksAny Permission = "links:any"
+	// PermUsersManage covers users and their API keys.
+	PermUsersManage Permission = "users:manage"
+	PermAuditRead   Permission = "audit:read"
+)
+
+// Roles lists every role from the least to the most privileged.
+var Roles = []Role{RoleViewer, RoleEditor, RoleAdmin}
+
+var grants = map[Role][]Permission{
+	RoleViewer: {PermLinksRead},
+	RoleEditor: {PermLinksRead, PermLinksWrite},
+	RoleAdmin:  {PermLinksRead, PermLinksWrite, PermLinksAny, PermUsersManage, PermAuditRead},
+}
+
+// Parse returns the role named s.
+func Parse(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := grants[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+
+	return role, nil
+}
+
+// Can reports whether the role grants p. Unknown roles grant nothing.
+func (r Role) Can(p Permission) bool {
+	for _, granted := range grants[r] {
+		if granted == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Cap returns the less privileged of r and limit, so an API key restricted to
+// limit never grants more than its user's role r. An empty limit leaves r as is.
+func (r Role) Cap(limit Role) Role {
+	if limit == "" || rank(r) <= rank(limit) {
+		return r
+	}
+
+	return limit
+}
+
+func rank(r Role) int {
+	for i, role := range Roles {
+		if role == r {
+			return i
+		}
+	}
+
+	return -1
+}
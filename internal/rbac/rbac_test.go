@@ -0,0 +1,87 @@
+package rbac_test
+
+import (
+	"testing"
+
+	"url-shortener/internal/rbac"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRole_Can(t *testing.T) {
+	cases := []struct {
+		role  rbac.Role
+		perms []rbac.Permission
+	}{
+		{
+			role:  rbac.RoleViewer,
+			perms: []rbac.Permission{rbac.PermLinksRead},
+		},
+		{
+			role:  rbac.RoleEditor,
+			perms: []rbac.Permission{rbac.PermLinksRead, rbac.PermLinksWrite},
+		},
+		{
+			role: rbac.RoleAdmin,
+			perms: []rbac.Permission{
+				rbac.PermLinksRead, rbac.PermLinksWrite, rbac.PermLinksAny,
+				rbac.PermUsersManage, rbac.PermAuditRead,
+			},
+		},
+		{
+			role: "root",
+		},
+		{
+			role: "",
+		},
+	}
+
+	all := []rbac.Permission{
+		rbac.PermLinksRead, rbac.PermLinksWrite, rbac.PermLinksAny,
+		rbac.PermUsersManage, rbac.PermAuditRead,
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(string(tc.role), func(t *testing.T) {
+			t.Parallel()
+
+			for _, p := range all {
+				require.Equal(t, contains(tc.perms, p), tc.role.Can(p), p)
+			}
+		})
+	}
+}
+
+func TestRole_Cap(t *testing.T) {
+	require.Equal(t, rbac.RoleEditor, rbac.RoleEditor.Cap(""))
+	require.Equal(t, rbac.RoleViewer, rbac.RoleEditor.Cap(rbac.RoleViewer))
+	require.Equal(t, rbac.RoleEditor, rbac.RoleEditor.Cap(rbac.RoleAdmin))
+	require.Equal(t, rbac.RoleEditor, rbac.RoleAdmin.Cap(rbac.RoleEditor))
+	require.Equal(t, rbac.RoleViewer, rbac.RoleViewer.Cap(rbac.RoleViewer))
+}
+
+func TestParse(t *testing.T) {
+	for _, role := range rbac.Roles {
+		parsed, err := rbac.Parse(string(role))
+		require.NoError(t, err)
+		require.Equal(t, role, parsed)
+	}
+
+	_, err := rbac.Parse("root")
+	require.Error(t, err)
+
+	_, err = rbac.Parse("")
+	require.Error(t, err)
+}
+
+func contains(perms []rbac.Permission, p rbac.Permission) bool {
+	for _, perm := range perms {
+		if perm == p {
+			return true
+		}
+	}
+
+	return false
+}
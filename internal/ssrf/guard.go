@@ -0,0 +1,359 @@
+package ssrf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/internal/lib/logger/sl"
+)
+
+// Reasons reported by Violation.
+const (
+	ReasonScheme  = "scheme"
+	ReasonHost    = "invalid_host"
+	ReasonSelf    = "self_reference"
+	ReasonAddress = "private_address"
+	ReasonUnknown = "unknown_host"
+)
+
+// Violation is returned by Check for destinations the guard rejects.
+type Violation struct {
+	Host   string
+	Reason string
+	// Detail names the offending scheme or address.
+	Detail string
+}
+
+func (v *Violation) Error() string {
+	switch v.Reason {
+	case ReasonScheme:
+		return fmt.Sprintf("scheme %q is not allowed", v.Detail)
+	case ReasonSelf:
+		return fmt.Sprintf("host %s points back at the shortener", v.Host)
+	case ReasonAddress:
+		return fmt.Sprintf("host %s resolves to non-public address %s", v.Host, v.Detail)
+	case ReasonUnknown:
+		return fmt.Sprintf("host %s does not resolve", v.Host)
+	default:
+		return fmt.Sprintf("host %q is not a valid host", v.Host)
+	}
+}
+
+// Resolver looks up the addresses of a host, *net.Resolver satisfies it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type Options struct {
+	// Schemes lists the allowed URL schemes, http and https when empty.
+	Schemes []string
+	// SelfHosts lists the domains the shortener itself is served from.
+	// Links to them would redirect back into the shortener.
+	SelfHosts []string
+	// TrustedHosts lists internal hosts that may be linked to even though
+	// they resolve to non-public addresses: exact hosts, "*.example.com"
+	// wildcards, IP addresses or CIDR ranges.
+	TrustedHosts []string
+	// Resolver defaults to net.DefaultResolver.
+	Resolver Resolver
+	// ResolveTimeout bounds a single host lookup.
+	ResolveTimeout time.Duration
+}
+
+// Guard rejects destinations that would turn the shortener into a way to
+// reach internal networks or into a redirect loop.
+type Guard struct {
+	log      *slog.Logger
+	schemes  map[string]bool
+	self     map[string]bool
+	trusted  []string
+	nets     []*net.IPNet
+	resolver Resolver
+	timeout  time.Duration
+}
+
+func New(log *slog.Logger, opts Options) (*Guard, error) {
+	const op = "ssrf.New"
+
+	g := &Guard{
+		log:      log,
+		schemes:  make(map[string]bool),
+		self:     make(map[string]bool),
+		resolver: opts.Resolver,
+		timeout:  opts.ResolveTimeout,
+	}
+
+	schemes := opts.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	for _, s := range schemes {
+		g.schemes[strings.ToLower(strings.TrimSpace(s))] = true
+	}
+
+	for _, h := range opts.SelfHosts {
+		if h = canonicalHost(h); h != "" {
+			g.self[h] = true
+		}
+	}
+
+	for _, h := range opts.TrustedHosts {
+		h = strings.TrimSpace(h)
+
+		if _, ipNet, err := net.ParseCIDR(h); err == nil {
+			g.nets = append(g.nets, ipNet)
+			continue
+		}
+		if ip := net.ParseIP(h); ip != nil {
+			g.nets = append(g.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		if strings.Contains(strings.TrimPrefix(h, "*."), "*") || h == "" {
+			return nil, fmt.Errorf("%s: invalid trusted host %q", op, h)
+		}
+
+		g.trusted = append(g.trusted, canonicalHost(h))
+	}
+
+	if g.resolver == nil {
+		g.resolver = net.DefaultResolver
+	}
+	if g.timeout <= 0 {
+		g.timeout = 2 * time.Second
+	}
+
+	return g, nil
+}
+
+// Check returns a *Violation when rawURL uses a scheme outside the allow
+// list, points at one of the shortener's own hosts, or names or resolves to
+// a loopback, private, shared, link-local or unspecified address. Hosts that
+// do not exist are rejected too, as they could later be pointed at an
+// internal address. Lookups failing for other reasons, such as timeouts,
+// return an error that is not a *Violation.
+func (g *Guard) Check(ctx context.Context, rawURL string) error {
+	const op = "ssrf.Guard.Check"
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &Violation{Host: rawURL, Reason: ReasonHost}
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if !g.schemes[scheme] {
+		return &Violation{Host: u.Hostname(), Reason: ReasonScheme, Detail: scheme}
+	}
+
+	host := canonicalHost(u.Hostname())
+	if host == "" {
+		return &Violation{Host: u.Host, Reason: ReasonHost}
+	}
+
+	if g.self[host] {
+		return &Violation{Host: host, Reason: ReasonSelf}
+	}
+
+	ip, numeric, ok := parseIP(host)
+	if !ok {
+		return &Violation{Host: host, Reason: ReasonHost}
+	}
+
+	if numeric {
+		if g.self[ip.String()] {
+			return &Violation{Host: host, Reason: ReasonSelf}
+		}
+		return g.checkIPs(host, ip)
+	}
+
+	if g.trustedHost(host) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	addrs, err := g.resolver.LookupIPAddr(ctx, host)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		g.log.Debug("destination host not found", slog.String("host", host), sl.Err(err))
+		return &Violation{Host: host, Reason: ReasonUnknown}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: resolve %s: %w", op, host, err)
+	}
+
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+
+	return g.checkIPs(host, ips...)
+}
+
+func (g *Guard) checkIPs(host string, ips ...net.IP) error {
+	for _, ip := range ips {
+		if public(ip) || g.trustedIP(ip) {
+			continue
+		}
+
+		return &Violation{Host: host, Reason: ReasonAddress, Detail: ip.String()}
+	}
+
+	return nil
+}
+
+func (g *Guard) trustedHost(host string) bool {
+	for _, t := range g.trusted {
+		if suffix, ok := strings.CutPrefix(t, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+			continue
+		}
+
+		if host == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *Guard) trustedIP(ip net.IP) bool {
+	for _, n := range g.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reserved lists the non-public ranges the net.IP predicates miss: "this
+// network", carrier-grade NAT and the NAT64 prefix, which embeds IPv4
+// addresses such as 127.0.0.1 in IPv6 ones.
+var reserved = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),
+	mustCIDR("100.64.0.0/10"),
+	mustCIDR("64:ff9b::/96"),
+}
+
+func mustCIDR(cidr string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+
+	return ipNet
+}
+
+func public(ip net.IP) bool {
+	for _, n := range reserved {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsUnspecified()
+}
+
+func canonicalHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	return strings.TrimSuffix(host, ".")
+}
+
+// parseIP reports whether host is an IP address. Besides the usual forms it
+// accepts the IPv4 spellings browsers still resolve, such as "2130706433",
+// "0x7f.1" or "0177.0.0.1". ok is false for hosts that look numeric but are
+// not a valid address.
+func parseIP(host string) (ip net.IP, numeric, ok bool) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, true, true
+	}
+
+	labels := strings.Split(host, ".")
+	if !numericLabel(labels[len(labels)-1]) {
+		return nil, false, true
+	}
+
+	if len(labels) > 4 {
+		return nil, true, false
+	}
+
+	parts := make([]uint64, len(labels))
+	for i, label := range labels {
+		n, err := parseIPv4Part(label)
+		if err != nil {
+			return nil, true, false
+		}
+		parts[i] = n
+	}
+
+	var addr uint64
+	for i, part := range parts[:len(parts)-1] {
+		if part > 255 {
+			return nil, true, false
+		}
+		addr |= part << (8 * (3 - i))
+	}
+
+	last := parts[len(parts)-1]
+	if last >= 1<<(8*(5-len(parts))) {
+		return nil, true, false
+	}
+	addr |= last
+
+	return net.IPv4(byte(addr>>24), byte(addr>>16), byte(addr>>8), byte(addr)), true, true
+}
+
+func numericLabel(label string) bool {
+	if label == "" {
+		return false
+	}
+
+	if hex, ok := strings.CutPrefix(label, "0x"); ok {
+		label = hex
+		for _, r := range label {
+			if !strings.ContainsRune("0123456789abcdef", r) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, r := range label {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseIPv4Part(label string) (uint64, error) {
+	switch {
+	case strings.HasPrefix(label, "0x"):
+		if label == "0x" {
+			return 0, nil
+		}
+		return strconv.ParseUint(label[2:], 16, 32)
+	case len(label) > 1 && label[0] == '0':
+		return strconv.ParseUint(label[1:], 8, 32)
+	default:
+		return strconv.ParseUint(label, 10, 32)
+	}
+}
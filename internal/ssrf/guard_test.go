@@ -0,0 +1,138 @@
+package ssrf_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/ssrf"
+
+	"github.com/stretchr/testify/require"
+)
+
+type resolver map[string][]string
+
+func (r resolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	if host == "timeout.example" {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+	}
+
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+
+	return addrs, nil
+}
+
+func TestGuard_Check(t *testing.T) {
+	guard, err := ssrf.New(slogdiscard.NewDiscardLogger(), ssrf.Options{
+		SelfHosts:    []string{"sho.rt", "www.sho.rt"},
+		TrustedHosts: []string{"wiki.corp", "*.svc.corp", "10.20.0.0/16"},
+		Resolver: resolver{
+			"example.com":     {"93.184.215.14", "2606:2800:21f:cb07:6820:80da:af6b:8b2c"},
+			"internal.corp":   {"10.0.0.5"},
+			"mixed.example":   {"93.184.215.14", "127.0.0.1"},
+			"wiki.corp":       {"10.0.0.6"},
+			"api.svc.corp":    {"10.0.0.7"},
+			"metrics.corp":    {"10.20.1.1"},
+			"rebind.example":  {"169.254.169.254"},
+			"v6local.example": {"fe80::1"},
+		},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		url    string
+		reason string
+		detail string
+	}{
+		{name: "Public host", url: "https://example.com/a"},
+		{name: "Unresolvable host", url: "https://nowhere.example/", reason: ssrf.ReasonUnknown},
+		{name: "Public IP", url: "http://8.8.8.8/"},
+		{name: "File scheme", url: "file:///etc/passwd", reason: ssrf.ReasonScheme, detail: "file"},
+		{name: "Javascript scheme", url: "javascript:alert(1)", reason: ssrf.ReasonScheme, detail: "javascript"},
+		{name: "Missing host", url: "http:///path", reason: ssrf.ReasonHost},
+		{name: "Own domain", url: "https://SHO.RT./abc", reason: ssrf.ReasonSelf},
+		{name: "Own subdomain", url: "https://www.sho.rt/abc", reason: ssrf.ReasonSelf},
+		{name: "Localhost IP", url: "http://127.0.0.1:8080/", reason: ssrf.ReasonAddress, detail: "127.0.0.1"},
+		{name: "IPv6 loopback", url: "http://[::1]/", reason: ssrf.ReasonAddress, detail: "::1"},
+		{name: "IPv4-mapped IPv6", url: "http://[::ffff:192.168.1.1]/", reason: ssrf.ReasonAddress, detail: "192.168.1.1"},
+		{name: "Unspecified", url: "http://0.0.0.0/", reason: ssrf.ReasonAddress, detail: "0.0.0.0"},
+		{name: "Private IP", url: "http://192.168.0.10/admin", reason: ssrf.ReasonAddress, detail: "192.168.0.10"},
+		{name: "Decimal IP", url: "http://2130706433/", reason: ssrf.ReasonAddress, detail: "127.0.0.1"},
+		{name: "Hex and short IP", url: "http://0x7f.1/", reason: ssrf.ReasonAddress, detail: "127.0.0.1"},
+		{name: "Octal IP", url: "http://0177.0.0.01/", reason: ssrf.ReasonAddress, detail: "127.0.0.1"},
+		{name: "Numeric host out of range", url: "http://1.2.3.256/", reason: ssrf.ReasonHost},
+		{name: "Numeric host with letters", url: "http://1.2.3.4a/", reason: ssrf.ReasonUnknown},
+		{name: "This network", url: "http://0.1.2.3/", reason: ssrf.ReasonAddress, detail: "0.1.2.3"},
+		{name: "Carrier-grade NAT", url: "http://100.64.0.1/", reason: ssrf.ReasonAddress, detail: "100.64.0.1"},
+		{name: "NAT64", url: "http://[64:ff9b::7f00:1]/", reason: ssrf.ReasonAddress, detail: "64:ff9b::7f00:1"},
+		{name: "Resolves to private", url: "https://internal.corp/", reason: ssrf.ReasonAddress, detail: "10.0.0.5"},
+		{name: "Any private address", url: "https://mixed.example/", reason: ssrf.ReasonAddress, detail: "127.0.0.1"},
+		{name: "Resolves to link-local", url: "http://rebind.example/latest/meta-data", reason: ssrf.ReasonAddress, detail: "169.254.169.254"},
+		{name: "Resolves to IPv6 link-local", url: "http://v6local.example/", reason: ssrf.ReasonAddress, detail: "fe80::1"},
+		{name: "Trusted host", url: "https://wiki.corp/page"},
+		{name: "Trusted wildcard", url: "https://api.svc.corp/"},
+		{name: "Trusted range", url: "https://metrics.corp/"},
+		{name: "Trusted range literal", url: "http://10.20.3.4/"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := guard.Check(context.Background(), tc.url)
+			if tc.reason == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var v *ssrf.Violation
+			require.True(t, errors.As(err, &v), err)
+			require.Equal(t, tc.reason, v.Reason)
+			require.Equal(t, tc.detail, v.Detail)
+		})
+	}
+}
+
+// TestGuard_LookupFailure keeps hosts whose lookup failed from passing as
+// safe, without calling them invalid either.
+func TestGuard_LookupFailure(t *testing.T) {
+	guard, err := ssrf.New(slogdiscard.NewDiscardLogger(), ssrf.Options{Resolver: resolver{}})
+	require.NoError(t, err)
+
+	err = guard.Check(context.Background(), "https://timeout.example/")
+	require.Error(t, err)
+
+	var v *ssrf.Violation
+	require.False(t, errors.As(err, &v))
+}
+
+func TestGuard_Schemes(t *testing.T) {
+	guard, err := ssrf.New(slogdiscard.NewDiscardLogger(), ssrf.Options{
+		Schemes:  []string{"https"},
+		Resolver: resolver{"example.com": {"93.184.215.14"}},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, guard.Check(context.Background(), "HTTPS://example.com"))
+	require.Error(t, guard.Check(context.Background(), "http://example.com"))
+}
+
+func TestNew_InvalidTrustedHost(t *testing.T) {
+	for _, h := range []string{"", "foo.*.corp"} {
+		_, err := ssrf.New(slogdiscard.NewDiscardLogger(), ssrf.Options{TrustedHosts: []string{h}})
+		require.Error(t, err, h)
+	}
+}
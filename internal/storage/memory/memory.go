@@ -0,0 +1,557 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener/internal/storage"
+)
+
+type Storage struct {
+	mu     sync.RWMutex
+	lastID int64
+	links  map[string]storage.Link
+	clicks map[string][]storage.Click
+
+	lastUserID int64
+	users      map[int64]storage.User
+
+	lastKeyID int64
+	keys      map[int64]storage.APIKey
+
+	audit []storage.AuditEntry
+}
+
+func New() *Storage {
+	return &Storage{
+		links:  make(map[string]storage.Link),
+		clicks: make(map[string][]storage.Click),
+		users:  make(map[int64]storage.User),
+		keys:   make(map[int64]storage.APIKey),
+	}
+}
+
+func (s *Storage) SaveURL(link storage.Link, audit ...storage.AuditEntry) error {
+	const op = "storage.memory.SaveURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[link.Alias]; ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+	}
+
+	s.lastID++
+	link.ID = s.lastID
+	link.CreatedAt = time.Now()
+	link.CanonicalURL = link.Canonical()
+	link.RedirectStatus = link.StatusCode()
+
+	s.links[link.Alias] = link
+	s.appendAudit(audit...)
+
+	return nil
+}
+
+func (s *Storage) SaveURLs(links []storage.Link, audit []storage.AuditEntry) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := make([]error, len(links))
+	failed := false
+	seen := make(map[string]bool, len(links))
+
+	for i, link := range links {
+		if _, ok := s.links[link.Alias]; ok || seen[link.Alias] {
+			errs[i] = storage.ErrURLExists
+			failed = true
+		}
+
+		seen[link.Alias] = true
+	}
+
+	if failed {
+		return errs, nil
+	}
+
+	now := time.Now()
+
+	for _, link := range links {
+		s.lastID++
+		link.ID = s.lastID
+		link.CanonicalURL = link.Canonical()
+		link.RedirectStatus = link.StatusCode()
+		link.CreatedAt = now
+
+		s.links[link.Alias] = link
+	}
+
+	s.appendAudit(audit...)
+
+	return errs, nil
+}
+
+func (s *Storage) GetURL(alias string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.links[alias]
+	if !ok {
+		return "", storage.ErrURLNotFound
+	}
+
+	if link.Expired(time.Now()) {
+		return "", storage.ErrURLExpired
+	}
+
+	return link.URL, nil
+}
+
+func (s *Storage) ResolveLink(alias string) (storage.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.links[alias]
+	if !ok {
+		return storage.Link{}, storage.ErrURLNotFound
+	}
+
+	if link.Expired(time.Now()) {
+		return storage.Link{}, storage.ErrURLExpired
+	}
+
+	return link, nil
+}
+
+func (s *Storage) GetLink(alias string) (storage.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.links[alias]
+	if !ok {
+		return storage.Link{}, storage.ErrURLNotFound
+	}
+
+	link.Clicks = int64(len(s.clicks[alias]))
+
+	return link, nil
+}
+
+func (s *Storage) FindGeneratedLink(ownerID int64, canonicalURL string) (storage.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *storage.Link
+
+	for _, link := range s.links {
+		if !link.Generated || link.OwnerID != ownerID || link.CanonicalURL != canonicalURL || link.ExpiresAt != nil ||
+			link.PasswordHash != "" {
+			continue
+		}
+
+		if found == nil || link.ID < found.ID {
+			link := link
+			found = &link
+		}
+	}
+
+	if found == nil {
+		return storage.Link{}, storage.ErrURLNotFound
+	}
+
+	return *found, nil
+}
+
+func (s *Storage) ListLinks(params storage.ListParams) ([]storage.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	desc := params.Order == storage.SortDesc
+
+	less := func(a, b storage.Link) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	}
+
+	var links []storage.Link
+
+	for _, link := range s.links {
+		if !link.OwnedBy(params.OwnerID) {
+			continue
+		}
+		if !strings.HasPrefix(link.Alias, params.AliasPrefix) {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(link.URL), strings.ToLower(params.URLContains)) {
+			continue
+		}
+		if params.Host != "" && storage.HostOf(link.CanonicalURL) != strings.ToLower(params.Host) {
+			continue
+		}
+
+		if params.After != nil {
+			after := storage.Link{CreatedAt: params.After.CreatedAt, ID: params.After.ID}
+			if desc && !less(link, after) || !desc && !less(after, link) {
+				continue
+			}
+		}
+
+		links = append(links, link)
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if desc {
+			return less(links[j], links[i])
+		}
+		return less(links[i], links[j])
+	})
+
+	if len(links) > params.Limit {
+		links = links[:params.Limit]
+	}
+
+	return links, nil
+}
+
+func (s *Storage) UpdateURL(alias string, ownerID int64, update storage.LinkUpdate, audit ...storage.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[alias]
+	if !ok {
+		return storage.ErrURLNotFound
+	}
+	if !link.OwnedBy(ownerID) {
+		return storage.ErrNotOwner
+	}
+
+	changed := update.Apply(link)
+
+	entries, err := storage.Changing(audit, link, changed)
+	if err != nil {
+		return err
+	}
+
+	s.links[alias] = changed
+	s.appendAudit(entries...)
+
+	return nil
+}
+
+func (s *Storage) DeleteAlias(alias string, ownerID int64, audit ...storage.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[alias]
+	if !ok {
+		return storage.ErrURLNotFound
+	}
+	if !link.OwnedBy(ownerID) {
+		return storage.ErrNotOwner
+	}
+
+	delete(s.links, alias)
+	delete(s.clicks, alias)
+	s.appendAudit(audit...)
+
+	return nil
+}
+
+func (s *Storage) DeleteExpired(before time.Time, limit int, audit storage.AuditEntry) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var links []storage.Link
+
+	for _, link := range s.links {
+		if len(links) >= limit {
+			break
+		}
+
+		if link.Expired(before) {
+			links = append(links, link)
+		}
+	}
+
+	entries, err := storage.PerLink(audit, links)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, link := range links {
+		delete(s.links, link.Alias)
+		delete(s.clicks, link.Alias)
+	}
+	s.appendAudit(entries...)
+
+	return int64(len(links)), nil
+}
+
+func (s *Storage) SaveClicks(clicks []storage.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, click := range clicks {
+		if _, ok := s.links[click.Alias]; !ok {
+			continue
+		}
+
+		s.clicks[click.Alias] = append(s.clicks[click.Alias], click)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetStats(alias string, ownerID int64, since time.Time, topReferrers int) (storage.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats storage.Stats
+
+	if link, ok := s.links[alias]; !ok || !link.OwnedBy(ownerID) {
+		return stats, storage.ErrURLNotFound
+	}
+
+	perDay := make(map[string]int64)
+	perReferrer := make(map[string]int64)
+
+	for _, click := range s.clicks[alias] {
+		stats.TotalClicks++
+
+		if click.Timestamp.Before(since) {
+			continue
+		}
+
+		perDay[click.Timestamp.UTC().Format(time.DateOnly)]++
+
+		if click.Referrer != "" {
+			perReferrer[click.Referrer]++
+		}
+	}
+
+	for date, clicks := range perDay {
+		stats.ClicksPerDay = append(stats.ClicksPerDay, storage.DailyClicks{Date: date, Clicks: clicks})
+	}
+	sort.Slice(stats.ClicksPerDay, func(i, j int) bool {
+		return stats.ClicksPerDay[i].Date < stats.ClicksPerDay[j].Date
+	})
+
+	for referrer, clicks := range perReferrer {
+		stats.TopReferrers = append(stats.TopReferrers, storage.ReferrerClicks{Referrer: referrer, Clicks: clicks})
+	}
+	sort.Slice(stats.TopReferrers, func(i, j int) bool {
+		a, b := stats.TopReferrers[i], stats.TopReferrers[j]
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.Referrer < b.Referrer
+	})
+	if len(stats.TopReferrers) > topReferrers {
+		stats.TopReferrers = stats.TopReferrers[:topReferrers]
+	}
+
+	return stats, nil
+}
+
+func (s *Storage) CreateUser(user storage.User, audit ...storage.AuditEntry) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Name == user.Name || user.SSO() && existing.Issuer == user.Issuer && existing.Subject == user.Subject {
+			return 0, storage.ErrUserExists
+		}
+	}
+
+	s.lastUserID++
+	user.ID = s.lastUserID
+	user.CreatedAt = time.Now()
+
+	s.users[user.ID] = user
+	s.appendAudit(storage.Targeting(user.ID, audit)...)
+
+	return user.ID, nil
+}
+
+func (s *Storage) GetUser(id int64) (storage.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return storage.User{}, storage.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (s *Storage) GetUserByName(name string) (storage.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+
+	return storage.User{}, storage.ErrUserNotFound
+}
+
+func (s *Storage) GetUserBySubject(issuer, subject string) (storage.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.SSO() && user.Issuer == issuer && user.Subject == subject {
+			return user, nil
+		}
+	}
+
+	return storage.User{}, storage.ErrUserNotFound
+}
+
+func (s *Storage) ListUsers() ([]storage.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]storage.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	return users, nil
+}
+
+func (s *Storage) CreateAPIKey(key storage.APIKey, audit ...storage.AuditEntry) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[key.UserID]; !ok {
+		return 0, storage.ErrUserNotFound
+	}
+
+	s.lastKeyID++
+	key.ID = s.lastKeyID
+	key.CreatedAt = time.Now()
+
+	s.keys[key.ID] = key
+	s.appendAudit(storage.Targeting(key.ID, audit)...)
+
+	return key.ID, nil
+}
+
+func (s *Storage) GetAPIKey(hash string) (storage.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.Hash == hash {
+			key.User = s.users[key.UserID].Name
+			return key, nil
+		}
+	}
+
+	return storage.APIKey{}, storage.ErrAPIKeyNotFound
+}
+
+func (s *Storage) ListAPIKeys() ([]storage.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]storage.APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		key.User = s.users[key.UserID].Name
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+func (s *Storage) RevokeAPIKey(id int64, at time.Time, audit ...storage.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return storage.ErrAPIKeyNotFound
+	}
+
+	// Revoking a revoked key changes nothing, so it is not audited again.
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	key.RevokedAt = &at
+	s.keys[id] = key
+	s.appendAudit(audit...)
+
+	return nil
+}
+
+func (s *Storage) TouchAPIKey(id int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return storage.ErrAPIKeyNotFound
+	}
+
+	key.LastUsedAt = &at
+	s.keys[id] = key
+
+	return nil
+}
+
+func (s *Storage) Close() error {
+	return nil
+}
+
+// appendAudit records the entries under the lock held for the change they
+// describe.
+func (s *Storage) appendAudit(entries ...storage.AuditEntry) {
+	for _, entry := range entries {
+		entry.ID = int64(len(s.audit)) + 1
+		entry.CreatedAt = time.Now()
+
+		s.audit = append(s.audit, entry)
+	}
+}
+
+func (s *Storage) ListAudit(filter storage.AuditFilter) ([]storage.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []storage.AuditEntry
+
+	for i := len(s.audit) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		e := s.audit[i]
+
+		switch {
+		case filter.Actor != "" && e.Actor != filter.Actor,
+			filter.Action != "" && e.Action != filter.Action,
+			filter.Alias != "" && e.Alias != filter.Alias,
+			filter.Since != nil && e.CreatedAt.Before(*filter.Since),
+			filter.Until != nil && !e.CreatedAt.Before(*filter.Until),
+			filter.BeforeID != 0 && e.ID >= filter.BeforeID:
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,572 @@
+package memory_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	s := memory.New()
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "example", URL: "https://example.com"}))
+	require.ErrorIs(t, s.SaveURL(storage.Link{Alias: "example", URL: "https://other.com"}), storage.ErrURLExists)
+
+	resURL, err := s.GetURL("example")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", resURL)
+
+	_, err = s.GetURL("unknown")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	require.NoError(t, s.DeleteAlias("example", 0))
+	require.ErrorIs(t, s.DeleteAlias("example", 0), storage.ErrURLNotFound)
+
+	_, err = s.GetURL("example")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "expired", URL: "https://example.com", ExpiresAt: &past}))
+
+	_, err = s.GetURL("expired")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	deleted, err := s.DeleteExpired(time.Now(), 10, storage.AuditEntry{Actor: "system", Action: "link.purge"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	entries, err := s.ListAudit(storage.AuditFilter{Action: "link.purge", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "expired", entries[0].Alias)
+	require.Equal(t, "system", entries[0].Actor)
+}
+
+func TestStorage_Stats(t *testing.T) {
+	s := memory.New()
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "stats", URL: "https://example.com"}))
+
+	now := time.Now()
+	clicks := []storage.Click{
+		{Alias: "stats", Timestamp: now.Add(-48 * time.Hour), Referrer: "https://old.com"},
+		{Alias: "stats", Timestamp: now.Add(-24 * time.Hour), Referrer: "https://a.com"},
+		{Alias: "stats", Timestamp: now, Referrer: "https://a.com"},
+		{Alias: "stats", Timestamp: now, Referrer: "https://b.com"},
+		{Alias: "stats", Timestamp: now},
+		{Alias: "unknown", Timestamp: now},
+	}
+	require.NoError(t, s.SaveClicks(clicks))
+
+	stats, err := s.GetStats("stats", 0, now.Add(-36*time.Hour), 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), stats.TotalClicks)
+	require.Len(t, stats.ClicksPerDay, 2)
+	require.Equal(t, now.Add(-24*time.Hour).UTC().Format(time.DateOnly), stats.ClicksPerDay[0].Date)
+	require.Equal(t, int64(3), stats.ClicksPerDay[1].Clicks)
+	require.Equal(t, []storage.ReferrerClicks{{Referrer: "https://a.com", Clicks: 2}}, stats.TopReferrers)
+
+	_, err = s.GetStats("unknown", 0, now, 10)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// Links of other users are not revealed to exist.
+	_, err = s.GetStats("stats", 7, now, 10)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	require.NoError(t, s.DeleteAlias("stats", 0))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "stats", URL: "https://example.com"}))
+
+	stats, err = s.GetStats("stats", 0, now.Add(-36*time.Hour), 1)
+	require.NoError(t, err)
+	require.Zero(t, stats.TotalClicks)
+}
+
+func TestStorage_Update(t *testing.T) {
+	s := memory.New()
+
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "update", URL: "https://typo.com", ExpiresAt: &past}))
+
+	newURL := "https://example.com"
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, s.UpdateURL("update", 0, storage.LinkUpdate{URL: &newURL, ExpiresAt: &future}))
+
+	resURL, err := s.GetURL("update")
+	require.NoError(t, err)
+	require.Equal(t, newURL, resURL)
+
+	require.NoError(t, s.UpdateURL("update", 0, storage.LinkUpdate{ClearExpiry: true}))
+
+	deleted, err := s.DeleteExpired(time.Now().Add(24*time.Hour), 10, storage.AuditEntry{})
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+
+	require.ErrorIs(t, s.UpdateURL("unknown", 0, storage.LinkUpdate{URL: &newURL}), storage.ErrURLNotFound)
+}
+
+func TestStorage_ListLinks(t *testing.T) {
+	s := memory.New()
+
+	links := []storage.Link{
+		{Alias: "go-docs", URL: "https://Go.dev/doc"},
+		{Alias: "go-blog", URL: "https://go.dev/blog"},
+		{Alias: "gh", URL: "https://github.com/golang/go"},
+		{Alias: "news", URL: "http://example.com:8080/golang-news"},
+	}
+	for _, link := range links {
+		require.NoError(t, s.SaveURL(link))
+	}
+
+	aliases := func(links []storage.Link) []string {
+		var res []string
+		for _, link := range links {
+			res = append(res, link.Alias)
+		}
+		return res
+	}
+
+	page, err := s.ListLinks(storage.ListParams{Order: storage.SortAsc, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"go-docs", "go-blog"}, aliases(page))
+	require.False(t, page[0].CreatedAt.IsZero())
+
+	last := page[len(page)-1]
+	page, err = s.ListLinks(storage.ListParams{
+		Order: storage.SortAsc,
+		After: &storage.Cursor{CreatedAt: last.CreatedAt, ID: last.ID},
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"gh", "news"}, aliases(page))
+
+	page, err = s.ListLinks(storage.ListParams{Order: storage.SortDesc, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"news", "gh", "go-blog", "go-docs"}, aliases(page))
+
+	page, err = s.ListLinks(storage.ListParams{AliasPrefix: "go-", Order: storage.SortAsc, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"go-docs", "go-blog"}, aliases(page))
+
+	page, err = s.ListLinks(storage.ListParams{URLContains: "GOLANG", Order: storage.SortAsc, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"gh", "news"}, aliases(page))
+
+	page, err = s.ListLinks(storage.ListParams{Host: "go.dev", Order: storage.SortAsc, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"go-docs", "go-blog"}, aliases(page))
+
+	page, err = s.ListLinks(storage.ListParams{Host: "example.com", Order: storage.SortAsc, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"news"}, aliases(page))
+}
+
+func TestStorage_GetLink(t *testing.T) {
+	s := memory.New()
+
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "info", URL: "https://example.com", Owner: "pedro", ExpiresAt: &past}))
+	require.NoError(t, s.SaveClicks([]storage.Click{
+		{Alias: "info", Timestamp: time.Now()},
+		{Alias: "info", Timestamp: time.Now()},
+	}))
+
+	link, err := s.GetLink("info")
+	require.NoError(t, err)
+	require.Equal(t, "info", link.Alias)
+	require.Equal(t, "https://example.com", link.URL)
+	require.Equal(t, "pedro", link.Owner)
+	require.False(t, link.CreatedAt.IsZero())
+	require.NotNil(t, link.ExpiresAt)
+	require.True(t, link.Expired(time.Now()))
+	require.Equal(t, int64(2), link.Clicks)
+
+	_, err = s.GetLink("unknown")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_SaveURLs(t *testing.T) {
+	s := memory.New()
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "taken", URL: "https://example.com"}))
+
+	errs, err := s.SaveURLs([]storage.Link{
+		{Alias: "first", URL: "https://example.com/1"},
+		{Alias: "taken", URL: "https://example.com/2"},
+		{Alias: "dup", URL: "https://example.com/3"},
+		{Alias: "dup", URL: "https://example.com/4"},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []error{nil, storage.ErrURLExists, nil, storage.ErrURLExists}, errs)
+
+	_, err = s.GetURL("first")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	errs, err = s.SaveURLs([]storage.Link{
+		{Alias: "first", URL: "https://example.com/1"},
+		{Alias: "second", URL: "https://example.com/2"},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []error{nil, nil}, errs)
+
+	resURL, err := s.GetURL("second")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/2", resURL)
+}
+
+func TestStorage_FindGeneratedLink(t *testing.T) {
+	s := memory.New()
+	pedro := mustCreateUser(t, s, "pedro")
+	maria := mustCreateUser(t, s, "maria")
+
+	future := time.Now().Add(time.Hour)
+	const dest = "https://example.com/page"
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "custom", URL: dest, Owner: "pedro", OwnerID: pedro}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "expiring", URL: dest, Owner: "pedro", OwnerID: pedro, Generated: true, ExpiresAt: &future}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "other", URL: dest, Owner: "maria", OwnerID: maria, Generated: true}))
+
+	_, err := s.FindGeneratedLink(pedro, dest)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "gen1", URL: dest, Owner: "pedro", OwnerID: pedro, Generated: true}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "gen2", URL: dest, Owner: "pedro", OwnerID: pedro, Generated: true}))
+
+	link, err := s.FindGeneratedLink(pedro, dest)
+	require.NoError(t, err)
+	require.Equal(t, "gen1", link.Alias)
+	require.Equal(t, dest, link.URL)
+	require.True(t, link.Generated)
+
+	_, err = s.FindGeneratedLink(pedro, dest+"?other")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	moved := "https://example.com/moved"
+	require.NoError(t, s.UpdateURL("gen1", 0, storage.LinkUpdate{URL: &moved}))
+
+	link, err = s.FindGeneratedLink(pedro, dest)
+	require.NoError(t, err)
+	require.Equal(t, "gen2", link.Alias)
+
+	link, err = s.FindGeneratedLink(pedro, moved)
+	require.NoError(t, err)
+	require.Equal(t, "gen1", link.Alias)
+}
+
+func TestStorage_CanonicalURL(t *testing.T) {
+	s := memory.New()
+	pedro := mustCreateUser(t, s, "pedro")
+
+	require.NoError(t, s.SaveURL(storage.Link{
+		Alias:        "canon",
+		URL:          "HTTPS://Example.COM:443/a/../b?utm_source=x",
+		CanonicalURL: "https://example.com/b",
+		Owner:        "pedro",
+		OwnerID:      pedro,
+		Generated:    true,
+	}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "plain", URL: "https://example.org/"}))
+
+	link, err := s.GetLink("canon")
+	require.NoError(t, err)
+	require.Equal(t, "HTTPS://Example.COM:443/a/../b?utm_source=x", link.URL)
+	require.Equal(t, "https://example.com/b", link.CanonicalURL)
+
+	link, err = s.GetLink("plain")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.org/", link.CanonicalURL)
+
+	link, err = s.FindGeneratedLink(pedro, "https://example.com/b")
+	require.NoError(t, err)
+	require.Equal(t, "canon", link.Alias)
+
+	links, err := s.ListLinks(storage.ListParams{Host: "example.com", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, "canon", links[0].Alias)
+
+	moved, canonical := "https://Example.NET/x", "https://example.net/x"
+	require.NoError(t, s.UpdateURL("canon", 0, storage.LinkUpdate{URL: &moved, CanonicalURL: &canonical}))
+
+	link, err = s.FindGeneratedLink(pedro, canonical)
+	require.NoError(t, err)
+	require.Equal(t, moved, link.URL)
+}
+
+func TestStorage_ResolveLink(t *testing.T) {
+	s := memory.New()
+	pedro := mustCreateUser(t, s, "pedro")
+
+	past := time.Now().Add(-time.Minute)
+	const dest = "https://example.com/doc"
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "locked", URL: dest, Owner: "pedro", OwnerID: pedro, Generated: true, PasswordHash: "hash", RedirectStatus: 308}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "expired", URL: dest, ExpiresAt: &past}))
+
+	link, err := s.ResolveLink("locked")
+	require.NoError(t, err)
+	require.Equal(t, "locked", link.Alias)
+	require.Equal(t, dest, link.URL)
+	require.Equal(t, "hash", link.PasswordHash)
+	require.Equal(t, 308, link.RedirectStatus)
+
+	link, err = s.GetLink("locked")
+	require.NoError(t, err)
+	require.Equal(t, "hash", link.PasswordHash)
+	require.Equal(t, 308, link.RedirectStatus)
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "plain", URL: dest}))
+
+	link, err = s.ResolveLink("plain")
+	require.NoError(t, err)
+	require.Equal(t, 302, link.RedirectStatus)
+	require.False(t, link.ForwardQuery)
+	require.False(t, link.ForwardPath)
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "forward", URL: dest, ForwardQuery: true, ForwardPath: true}))
+
+	link, err = s.ResolveLink("forward")
+	require.NoError(t, err)
+	require.True(t, link.ForwardQuery)
+	require.True(t, link.ForwardPath)
+
+	_, err = s.ResolveLink("expired")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+
+	_, err = s.ResolveLink("unknown")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// Protected links are never handed out by deduplication.
+	_, err = s.FindGeneratedLink(pedro, dest)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_APIKeys(t *testing.T) {
+	s := memory.New()
+
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	billing := mustCreateUser(t, s, "billing")
+
+	_, err := s.CreateAPIKey(storage.APIKey{Name: "ci", UserID: 42, Prefix: "usk_xxxx", Hash: "hash-x"})
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	id, err := s.CreateAPIKey(storage.APIKey{Name: "ci", UserID: billing, Prefix: "usk_aaaa", Hash: "hash-a", ExpiresAt: &future})
+	require.NoError(t, err)
+
+	otherID, err := s.CreateAPIKey(storage.APIKey{Name: "bot", UserID: billing, Role: "viewer", Prefix: "usk_bbbb", Hash: "hash-b"})
+	require.NoError(t, err)
+	require.NotEqual(t, id, otherID)
+
+	key, err := s.GetAPIKey("hash-a")
+	require.NoError(t, err)
+	require.Equal(t, id, key.ID)
+	require.Equal(t, "ci", key.Name)
+	require.Equal(t, "usk_aaaa", key.Prefix)
+	require.Equal(t, billing, key.UserID)
+	require.Equal(t, "billing", key.User)
+	require.Empty(t, key.Role)
+	require.WithinDuration(t, future, *key.ExpiresAt, time.Second)
+	require.Nil(t, key.LastUsedAt)
+	require.True(t, key.Active(time.Now()))
+
+	_, err = s.GetAPIKey("unknown")
+	require.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+
+	used := time.Now()
+	require.NoError(t, s.TouchAPIKey(id, used))
+
+	revoke := storage.AuditEntry{Actor: "pedro", Action: "key.revoke", TargetID: id}
+
+	revoked := time.Now()
+	require.NoError(t, s.RevokeAPIKey(id, revoked, revoke))
+	// Revoking again keeps the original time and is not audited.
+	require.NoError(t, s.RevokeAPIKey(id, revoked.Add(time.Hour), revoke))
+	require.ErrorIs(t, s.RevokeAPIKey(42, revoked, revoke), storage.ErrAPIKeyNotFound)
+
+	entries, err := s.ListAudit(storage.AuditFilter{Action: "key.revoke", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	keys, err := s.ListAPIKeys()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, "ci", keys[0].Name)
+	require.WithinDuration(t, used, *keys[0].LastUsedAt, time.Second)
+	require.WithinDuration(t, revoked, *keys[0].RevokedAt, time.Second)
+	require.False(t, keys[0].Active(time.Now()))
+	require.Equal(t, "bot", keys[1].Name)
+	require.Equal(t, "viewer", keys[1].Role)
+	require.Nil(t, keys[1].RevokedAt)
+}
+
+func TestStorage_Users(t *testing.T) {
+	s := memory.New()
+
+	pedro := mustCreateUser(t, s, "pedro")
+
+	id, err := s.CreateUser(storage.User{Name: "admin", Role: "admin"})
+	require.NoError(t, err)
+
+	_, err = s.CreateUser(storage.User{Name: "pedro"})
+	require.ErrorIs(t, err, storage.ErrUserExists)
+
+	user, err := s.GetUser(id)
+	require.NoError(t, err)
+	require.Equal(t, "admin", user.Name)
+	require.Equal(t, "admin", user.Role)
+	require.False(t, user.CreatedAt.IsZero())
+
+	user, err = s.GetUserByName("pedro")
+	require.NoError(t, err)
+	require.Equal(t, pedro, user.ID)
+	require.Equal(t, "editor", user.Role)
+
+	_, err = s.GetUser(42)
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	_, err = s.GetUserByName("maria")
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	users, err := s.ListUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, "pedro", users[0].Name)
+	require.Equal(t, "admin", users[1].Name)
+}
+
+func TestStorage_Ownership(t *testing.T) {
+	s := memory.New()
+
+	pedro := mustCreateUser(t, s, "pedro")
+	maria := mustCreateUser(t, s, "maria")
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "mine", URL: "https://example.com/a", Owner: "pedro", OwnerID: pedro}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "theirs", URL: "https://example.com/b", Owner: "maria", OwnerID: maria}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "legacy", URL: "https://example.com/c"}))
+
+	link, err := s.GetLink("mine")
+	require.NoError(t, err)
+	require.Equal(t, pedro, link.OwnerID)
+
+	links, err := s.ListLinks(storage.ListParams{OwnerID: pedro, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, "mine", links[0].Alias)
+	require.Equal(t, pedro, links[0].OwnerID)
+
+	links, err = s.ListLinks(storage.ListParams{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, links, 3)
+
+	newURL := "https://example.com/new"
+	update := storage.LinkUpdate{URL: &newURL}
+
+	require.ErrorIs(t, s.UpdateURL("theirs", pedro, update), storage.ErrNotOwner)
+	require.ErrorIs(t, s.UpdateURL("legacy", pedro, update), storage.ErrNotOwner)
+	require.ErrorIs(t, s.UpdateURL("unknown", pedro, update), storage.ErrURLNotFound)
+	require.NoError(t, s.UpdateURL("mine", pedro, update))
+	require.NoError(t, s.UpdateURL("theirs", 0, update))
+
+	require.ErrorIs(t, s.DeleteAlias("theirs", pedro), storage.ErrNotOwner)
+	require.ErrorIs(t, s.DeleteAlias("unknown", pedro), storage.ErrURLNotFound)
+	require.NoError(t, s.DeleteAlias("mine", pedro))
+	require.NoError(t, s.DeleteAlias("legacy", 0))
+
+	_, err = s.GetLink("theirs")
+	require.NoError(t, err)
+}
+
+func TestStorage_AuditedUpdate(t *testing.T) {
+	s := memory.New()
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, s.SaveURL(storage.Link{
+		Alias:          "a",
+		URL:            "https://old.example.com/",
+		CanonicalURL:   "https://old.example.com/",
+		ExpiresAt:      &expiresAt,
+		PasswordHash:   "hash",
+		RedirectStatus: 301,
+	}))
+
+	// The states of the link are read in the transaction of the update.
+	newURL := "https://New.example.com/"
+	canonical := "https://new.example.com/"
+	update := storage.LinkUpdate{URL: &newURL, CanonicalURL: &canonical, ClearExpiry: true}
+	require.NoError(t, s.UpdateURL("a", 0, update, storage.AuditEntry{Actor: "pedro", Action: "link.update", Alias: "a"}))
+
+	entries, err := s.ListAudit(storage.AuditFilter{Action: "link.update", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.JSONEq(t, `{"alias":"a","url":"https://old.example.com/","canonical_url":"https://old.example.com/",
+		"expires_at":"2030-01-01T00:00:00Z","password_protected":true,"redirect_status":301}`, entries[0].Before)
+	require.JSONEq(t, `{"alias":"a","url":"https://New.example.com/","canonical_url":"https://new.example.com/",
+		"password_protected":true,"redirect_status":301}`, entries[0].After)
+}
+
+func TestStorage_AuditedChanges(t *testing.T) {
+	s := memory.New()
+
+	entry := func(action, alias string) storage.AuditEntry {
+		return storage.AuditEntry{Actor: "pedro", Action: action, Alias: alias}
+	}
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "a", URL: "https://example.com"}, entry("link.create", "a")))
+
+	// A failed change leaves no entry behind.
+	err := s.SaveURL(storage.Link{Alias: "a", URL: "https://example.com"}, entry("link.create", "a"))
+	require.ErrorIs(t, err, storage.ErrURLExists)
+	require.ErrorIs(t, s.DeleteAlias("missing", 0, entry("link.delete", "missing")), storage.ErrURLNotFound)
+
+	errs, err := s.SaveURLs([]storage.Link{
+		{Alias: "a", URL: "https://example.com/1"},
+		{Alias: "b", URL: "https://example.com/2"},
+	}, []storage.AuditEntry{entry("link.create", "a"), entry("link.create", "b")})
+	require.NoError(t, err)
+	require.Equal(t, []error{storage.ErrURLExists, nil}, errs)
+
+	newURL := "https://example.org"
+	require.NoError(t, s.UpdateURL("a", 0, storage.LinkUpdate{URL: &newURL}, entry("link.update", "a")))
+	require.NoError(t, s.DeleteAlias("a", 0, entry("link.delete", "a")))
+
+	// Creations record the id of the new object as the target.
+	userID, err := s.CreateUser(storage.User{Name: "maria", Role: "editor"}, entry("user.create", ""))
+	require.NoError(t, err)
+
+	keyID, err := s.CreateAPIKey(storage.APIKey{Name: "ci", UserID: userID, Prefix: "usk_ci", Hash: "hash"}, entry("key.create", ""))
+	require.NoError(t, err)
+
+	revoke := entry("key.revoke", "")
+	revoke.TargetID = keyID
+	require.NoError(t, s.RevokeAPIKey(keyID, time.Now(), revoke))
+
+	entries, err := s.ListAudit(storage.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, fmt.Sprintf("%s %s %d", e.Action, e.Alias, e.TargetID))
+	}
+	require.Equal(t, []string{
+		fmt.Sprintf("key.revoke  %d", keyID),
+		fmt.Sprintf("key.create  %d", keyID),
+		fmt.Sprintf("user.create  %d", userID),
+		"link.delete a 0",
+		"link.update a 0",
+		"link.create a 0",
+	}, got)
+}
+
+func mustCreateUser(t *testing.T, s *memory.Storage, name string) int64 {
+	t.Helper()
+
+	id, err := s.CreateUser(storage.User{Name: name, Role: "editor"})
+	require.NoError(t, err)
+
+	return id
+}
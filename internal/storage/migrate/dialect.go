@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// lockID identifies the url-shortener migration lock in pg_advisory_lock.
+const lockID = 7_351_201_923
+
+// Dialect holds the database specific parts of the migration runner.
+type Dialect struct {
+	Placeholder func(n int) string
+	Lock        func(ctx context.Context, conn *sql.Conn) error
+	Unlock      func(ctx context.Context, conn *sql.Conn) error
+}
+
+var Postgres = Dialect{
+	Placeholder: func(n int) string {
+		return fmt.Sprintf("$%d", n)
+	},
+	Lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID)
+		return err
+	},
+	Unlock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockID)
+		return err
+	},
+}
+
+// SQLite has no advisory locks; every migration runs in its own write
+// transaction and SQLite allows a single writer per database file.
+var SQLite = Dialect{
+	Placeholder: func(int) string {
+		return "?"
+	},
+	Lock: func(context.Context, *sql.Conn) error {
+		return nil
+	},
+	Unlock: func(context.Context, *sql.Conn) error {
+		return nil
+	},
+}
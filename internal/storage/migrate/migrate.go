@@ -0,0 +1,317 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoMigrations = errors.New("no migrations to roll back")
+)
+
+// Migration is a numbered pair of up/down SQL scripts.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a known migration has been applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New loads migrations from fsys. Every migration is a pair of files named
+// NNNN_name.up.sql and NNNN_name.down.sql in the root of fsys.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	const op = "storage.migrate.New"
+
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies all pending migrations and returns the applied ones.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	const op = "storage.migrate.Up"
+
+	var done []Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			insert := fmt.Sprintf(
+				"INSERT INTO schema_migrations(version, name) VALUES(%s, %s)",
+				m.dialect.Placeholder(1), m.dialect.Placeholder(2),
+			)
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+					return err
+				}
+
+				_, err := tx.ExecContext(ctx, insert, mig.Version, mig.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("apply %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return done, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	const op = "storage.migrate.Down"
+
+	var done Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+
+			remove := fmt.Sprintf(
+				"DELETE FROM schema_migrations WHERE version = %s",
+				m.dialect.Placeholder(1),
+			)
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+					return err
+				}
+
+				_, err := tx.ExecContext(ctx, remove, mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("roll back %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			done = mig
+
+			return nil
+		}
+
+		return ErrNoMigrations
+	})
+	if err != nil {
+		return Migration{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return done, nil
+}
+
+// Status reports every known migration along with its applied state.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	const op = "storage.migrate.Status"
+
+	var statuses []Status
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			appliedAt, ok := applied[mig.Version]
+
+			statuses = append(statuses, Status{
+				Migration: mig,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return statuses, nil
+}
+
+// locked runs fn on a single connection holding the migration lock, so that
+// concurrently starting replicas do not apply the same migration twice.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := m.dialect.Lock(ctx, conn); err != nil {
+		return fmt.Errorf("acquire lock: %w", err)
+	}
+	defer func() { _ = m.dialect.Unlock(context.Background(), conn) }()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations(
+			version BIGINT PRIMARY KEY,
+			name VARCHAR NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := make(map[int64]time.Time)
+
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, file := range files {
+		base := path.Base(file)
+
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", base)
+		}
+
+		stem := strings.TrimSuffix(base, "."+direction+".sql")
+
+		rawVersion, name, ok := strings.Cut(stem, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name prefix", base)
+		}
+
+		version, err := strconv.ParseInt(rawVersion, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", base, err)
+		}
+
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: name}
+			byVersion[version] = mig
+		}
+		if mig.Name != name {
+			return nil, fmt.Errorf("migration %s: version %d already used by %s", base, version, mig.Name)
+		}
+
+		if direction == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s: both up and down scripts are required", mig.Version, mig.Name)
+		}
+
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,99 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"url-shortener/internal/storage/migrate"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	fsys := fstest.MapFS{
+		"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a(id INTEGER);")},
+		"0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b(id INTEGER);")},
+		"0002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	}
+
+	m, err := migrate.New(db, migrate.SQLite, fsys)
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+	require.Equal(t, int64(1), applied[0].Version)
+	require.Equal(t, "create_b", applied[1].Name)
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	require.Empty(t, applied)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.True(t, statuses[0].Applied)
+	require.False(t, statuses[1].AppliedAt.IsZero())
+
+	rolledBack, err := m.Down(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rolledBack.Version)
+
+	_, err = db.Exec("SELECT id FROM b")
+	require.Error(t, err)
+
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, statuses[0].Applied)
+	require.False(t, statuses[1].Applied)
+
+	_, err = m.Down(ctx)
+	require.NoError(t, err)
+
+	_, err = m.Down(ctx)
+	require.ErrorIs(t, err, migrate.ErrNoMigrations)
+}
+
+func TestNew_InvalidFiles(t *testing.T) {
+	cases := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "Missing down",
+			fsys: fstest.MapFS{
+				"0001_create_a.up.sql": {Data: []byte("CREATE TABLE a(id INTEGER);")},
+			},
+		},
+		{
+			name: "Invalid version",
+			fsys: fstest.MapFS{
+				"first_create_a.up.sql": {Data: []byte("CREATE TABLE a(id INTEGER);")},
+			},
+		},
+		{
+			name: "Invalid suffix",
+			fsys: fstest.MapFS{
+				"0001_create_a.sql": {Data: []byte("CREATE TABLE a(id INTEGER);")},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := migrate.New(nil, migrate.SQLite, tc.fsys)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_alias;
+
+DROP TABLE IF EXISTS url;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS url(
+	id SERIAL PRIMARY KEY,
+	url VARCHAR NOT NULL,
+	alias VARCHAR NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
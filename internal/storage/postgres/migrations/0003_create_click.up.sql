@@ -0,0 +1,10 @@
+CREATE TABLE click(
+	id BIGSERIAL PRIMARY KEY,
+	url_id INTEGER NOT NULL REFERENCES url(id) ON DELETE CASCADE,
+	clicked_at TIMESTAMPTZ NOT NULL,
+	referrer VARCHAR NOT NULL DEFAULT '',
+	user_agent VARCHAR NOT NULL DEFAULT '',
+	ip VARCHAR NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_click_url_id_clicked_at ON click(url_id, clicked_at);
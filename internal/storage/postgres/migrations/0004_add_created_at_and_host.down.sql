@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_url_host;
+
+DROP INDEX IF EXISTS idx_url_created_at_id;
+
+ALTER TABLE url DROP COLUMN host;
+
+ALTER TABLE url DROP COLUMN created_at;
@@ -0,0 +1,9 @@
+ALTER TABLE url ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+ALTER TABLE url ADD COLUMN host VARCHAR NOT NULL DEFAULT '';
+
+UPDATE url SET host = lower(coalesce(substring(url from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^@/]*@)?([^/:?#]+)'), ''));
+
+CREATE INDEX idx_url_created_at_id ON url(created_at, id);
+
+CREATE INDEX idx_url_host ON url(host);
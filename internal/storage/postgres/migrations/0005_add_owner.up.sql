@@ -0,0 +1 @@
+ALTER TABLE url ADD COLUMN owner VARCHAR NOT NULL DEFAULT '';
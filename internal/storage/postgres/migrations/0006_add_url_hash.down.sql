@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_url_owner_url_hash;
+
+ALTER TABLE url DROP COLUMN generated;
+
+ALTER TABLE url DROP COLUMN url_hash;
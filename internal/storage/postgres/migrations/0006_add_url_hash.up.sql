@@ -0,0 +1,7 @@
+ALTER TABLE url ADD COLUMN url_hash VARCHAR NOT NULL DEFAULT '';
+
+ALTER TABLE url ADD COLUMN generated BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE url SET url_hash = encode(sha256(convert_to(url, 'UTF8')), 'hex');
+
+CREATE INDEX idx_url_owner_url_hash ON url(owner, url_hash);
@@ -0,0 +1,3 @@
+ALTER TABLE url ADD COLUMN canonical_url VARCHAR NOT NULL DEFAULT '';
+
+UPDATE url SET canonical_url = url;
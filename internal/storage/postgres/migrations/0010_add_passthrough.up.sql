@@ -0,0 +1,3 @@
+ALTER TABLE url ADD COLUMN forward_query BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE url ADD COLUMN forward_path BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,10 @@
+CREATE TABLE api_key(
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	prefix VARCHAR NOT NULL,
+	hash VARCHAR NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+);
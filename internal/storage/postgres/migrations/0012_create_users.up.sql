@@ -0,0 +1,24 @@
+CREATE TABLE users(
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR NOT NULL UNIQUE,
+	admin BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Existing owners and API key names become users, so links and keys keep
+-- belonging to whoever created them. Links created with a key were owned by
+-- its name, so a key named like an owner, the basic auth user included,
+-- belongs to that user and acts with its role rather than as the account.
+INSERT INTO users(name) SELECT DISTINCT owner FROM url WHERE owner <> '';
+
+INSERT INTO users(name) SELECT DISTINCT name FROM api_key WHERE name NOT IN (SELECT name FROM users);
+
+ALTER TABLE url ADD COLUMN owner_id BIGINT REFERENCES users(id);
+
+UPDATE url SET owner_id = users.id FROM users WHERE users.name = url.owner;
+
+CREATE INDEX idx_url_owner_id ON url(owner_id);
+
+ALTER TABLE api_key ADD COLUMN user_id BIGINT REFERENCES users(id);
+
+UPDATE api_key SET user_id = users.id FROM users WHERE users.name = api_key.name;
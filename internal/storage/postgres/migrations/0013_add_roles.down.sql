@@ -0,0 +1,7 @@
+ALTER TABLE api_key DROP COLUMN role;
+
+ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET admin = TRUE WHERE role = 'admin';
+
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,9 @@
+-- Roles replace the admin flag, users that were not admins keep creating and
+-- changing their own links as editors.
+ALTER TABLE users ADD COLUMN role VARCHAR NOT NULL DEFAULT 'editor';
+
+UPDATE users SET role = 'admin' WHERE admin;
+
+ALTER TABLE users DROP COLUMN admin;
+
+ALTER TABLE api_key ADD COLUMN role VARCHAR NOT NULL DEFAULT '';
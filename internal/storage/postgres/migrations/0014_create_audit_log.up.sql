@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS audit_log(
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	actor VARCHAR NOT NULL,
+	actor_id BIGINT NOT NULL DEFAULT 0,
+	action VARCHAR NOT NULL,
+	alias VARCHAR NOT NULL DEFAULT '',
+	before_value JSONB,
+	after_value JSONB,
+	request_id VARCHAR NOT NULL DEFAULT '',
+	client_ip VARCHAR NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_alias ON audit_log(alias);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor);
+
+-- The audit log is append-only, entries can neither be changed nor removed.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_change BEFORE UPDATE OR DELETE ON audit_log
+	FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
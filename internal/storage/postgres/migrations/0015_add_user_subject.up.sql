@@ -0,0 +1,6 @@
+-- SSO users are identified by the issuer and subject of their tokens rather
+-- than by name, so tokens cannot sign in as local users of the same name.
+ALTER TABLE users ADD COLUMN issuer VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN subject VARCHAR NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_subject ON users(issuer, subject) WHERE subject <> '';
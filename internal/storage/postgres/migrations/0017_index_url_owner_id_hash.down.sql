@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_url_owner_id_url_hash;
+
+CREATE INDEX IF NOT EXISTS idx_url_owner_url_hash ON url(owner, url_hash);
@@ -0,0 +1,268 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/postgres"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newStorage returns a storage migrated in a schema of its own in the
+// database of POSTGRES_DSN, and skips the test when it is not set.
+func newStorage(t *testing.T) *postgres.Storage {
+	t.Helper()
+
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = db.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	s, err := postgres.New(withSearchPath(dsn, schema))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	m, err := s.Migrator()
+	require.NoError(t, err)
+
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+
+	return s
+}
+
+// withSearchPath adds search_path to dsn, given either as a URL or as
+// key=value pairs.
+func withSearchPath(dsn, schema string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return dsn + " search_path=" + schema
+	}
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func TestStorage_Ownership(t *testing.T) {
+	s := newStorage(t)
+
+	pedro := mustCreateUser(t, s, "pedro")
+	maria := mustCreateUser(t, s, "maria")
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "mine", URL: "https://example.com/a", Owner: "pedro", OwnerID: pedro}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "theirs", URL: "https://example.com/b", Owner: "maria", OwnerID: maria}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "legacy", URL: "https://example.com/c"}))
+
+	links, err := s.ListLinks(storage.ListParams{OwnerID: pedro, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, "mine", links[0].Alias)
+
+	_, err = s.GetStats("theirs", pedro, time.Now().Add(-time.Hour), 1)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	newURL := "https://example.com/new"
+	update := storage.LinkUpdate{URL: &newURL}
+
+	require.ErrorIs(t, s.UpdateURL("theirs", pedro, update), storage.ErrNotOwner)
+	require.ErrorIs(t, s.UpdateURL("legacy", pedro, update), storage.ErrNotOwner)
+	require.ErrorIs(t, s.UpdateURL("unknown", pedro, update), storage.ErrURLNotFound)
+	require.NoError(t, s.UpdateURL("mine", pedro, update))
+	require.NoError(t, s.UpdateURL("theirs", 0, update))
+
+	require.ErrorIs(t, s.DeleteAlias("theirs", pedro), storage.ErrNotOwner)
+	require.ErrorIs(t, s.DeleteAlias("unknown", pedro), storage.ErrURLNotFound)
+	require.NoError(t, s.DeleteAlias("mine", pedro))
+	require.NoError(t, s.DeleteAlias("legacy", 0))
+
+	_, err = s.GetLink("theirs")
+	require.NoError(t, err)
+}
+
+func TestStorage_ListLinks(t *testing.T) {
+	s := newStorage(t)
+
+	for _, alias := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, s.SaveURL(storage.Link{Alias: alias, URL: "https://example.com/" + alias}))
+	}
+
+	for _, tc := range []struct {
+		order storage.SortOrder
+		want  []string
+	}{
+		{order: storage.SortAsc, want: []string{"a", "b", "c", "d", "e"}},
+		{order: storage.SortDesc, want: []string{"e", "d", "c", "b", "a"}},
+	} {
+		var got []string
+		var after *storage.Cursor
+
+		for {
+			page, err := s.ListLinks(storage.ListParams{Order: tc.order, After: after, Limit: 2})
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+
+			for _, link := range page {
+				got = append(got, link.Alias)
+			}
+
+			last := page[len(page)-1]
+			after = &storage.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+
+		require.Equal(t, tc.want, got, tc.order)
+	}
+}
+
+func TestStorage_SaveURLs(t *testing.T) {
+	s := newStorage(t)
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "taken", URL: "https://example.com"}))
+
+	errs, err := s.SaveURLs([]storage.Link{
+		{Alias: "first", URL: "https://example.com/1"},
+		{Alias: "taken", URL: "https://example.com/2"},
+		{Alias: "dup", URL: "https://example.com/3"},
+		{Alias: "dup", URL: "https://example.com/4"},
+	}, []storage.AuditEntry{{Action: "link.create", Alias: "first"}})
+	require.NoError(t, err)
+	require.Equal(t, []error{nil, storage.ErrURLExists, nil, storage.ErrURLExists}, errs)
+
+	_, err = s.GetURL("first")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	entries, err := s.ListAudit(storage.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	errs, err = s.SaveURLs([]storage.Link{
+		{Alias: "first", URL: "https://example.com/1"},
+		{Alias: "second", URL: "https://example.com/2"},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []error{nil, nil}, errs)
+
+	resURL, err := s.GetURL("second")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/2", resURL)
+}
+
+func TestStorage_AuditedUpdate(t *testing.T) {
+	s := newStorage(t)
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, s.SaveURL(storage.Link{
+		Alias:          "a",
+		URL:            "https://old.example.com/",
+		CanonicalURL:   "https://old.example.com/",
+		ExpiresAt:      &expiresAt,
+		PasswordHash:   "hash",
+		RedirectStatus: 301,
+	}))
+
+	// The states of the link are read in the transaction of the update.
+	newURL := "https://New.example.com/"
+	canonical := "https://new.example.com/"
+	update := storage.LinkUpdate{URL: &newURL, CanonicalURL: &canonical, ClearExpiry: true}
+	require.NoError(t, s.UpdateURL("a", 0, update, storage.AuditEntry{Actor: "pedro", Action: "link.update", Alias: "a"}))
+
+	entries, err := s.ListAudit(storage.AuditFilter{Action: "link.update", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.JSONEq(t, `{"alias":"a","url":"https://old.example.com/","canonical_url":"https://old.example.com/",
+		"expires_at":"2030-01-01T00:00:00Z","password_protected":true,"redirect_status":301}`, entries[0].Before)
+	require.JSONEq(t, `{"alias":"a","url":"https://New.example.com/","canonical_url":"https://new.example.com/",
+		"password_protected":true,"redirect_status":301}`, entries[0].After)
+}
+
+func TestStorage_DeleteExpired(t *testing.T) {
+	s := newStorage(t)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	for _, alias := range []string{"a", "b", "c"} {
+		require.NoError(t, s.SaveURL(storage.Link{Alias: alias, URL: "https://example.com", ExpiresAt: &past}))
+	}
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "future", URL: "https://example.com", ExpiresAt: &future}))
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "forever", URL: "https://example.com"}))
+
+	purge := storage.AuditEntry{Actor: "system", Action: "link.purge"}
+
+	deleted, err := s.DeleteExpired(time.Now(), 2, purge)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+
+	deleted, err = s.DeleteExpired(time.Now(), 2, purge)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	entries, err := s.ListAudit(storage.AuditFilter{Action: "link.purge", Limit: 10})
+	require.NoError(t, err)
+
+	var purged []string
+	for _, entry := range entries {
+		purged = append(purged, entry.Alias)
+
+		var before storage.LinkState
+		require.NoError(t, json.Unmarshal([]byte(entry.Before), &before))
+		require.Equal(t, entry.Alias, before.Alias)
+		require.NotNil(t, before.ExpiresAt)
+	}
+	require.ElementsMatch(t, []string{"a", "b", "c"}, purged)
+
+	_, err = s.GetURL("future")
+	require.NoError(t, err)
+
+	_, err = s.GetURL("forever")
+	require.NoError(t, err)
+}
+
+// TestStorage_SaveClicksChunks saves more clicks than the 65535 parameters
+// of a single statement allow.
+func TestStorage_SaveClicksChunks(t *testing.T) {
+	s := newStorage(t)
+
+	require.NoError(t, s.SaveURL(storage.Link{Alias: "busy", URL: "https://example.com"}))
+
+	now := time.Now()
+	clicks := make([]storage.Click, 14000)
+	for i := range clicks {
+		clicks[i] = storage.Click{Alias: "busy", Timestamp: now}
+	}
+	require.NoError(t, s.SaveClicks(clicks))
+
+	stats, err := s.GetStats("busy", 0, now.Add(-time.Hour), 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(14000), stats.TotalClicks)
+}
+
+func mustCreateUser(t *testing.T, s *postgres.Storage, name string) int64 {
+	t.Helper()
+
+	id, err := s.CreateUser(storage.User{Name: name, Role: "editor"})
+	require.NoError(t, err)
+
+	return id
+}
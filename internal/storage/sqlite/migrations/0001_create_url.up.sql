@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS url(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	alias TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
@@ -0,0 +1,10 @@
+CREATE TABLE click(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url_id INTEGER NOT NULL REFERENCES url(id) ON DELETE CASCADE,
+	clicked_at TIMESTAMP NOT NULL,
+	referrer TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_click_url_id_clicked_at ON click(url_id, clicked_at);
@@ -0,0 +1,19 @@
+-- SQLite cannot add a column with a non-constant default, existing rows get
+-- the migration time and new rows always set created_at explicitly.
+ALTER TABLE url ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+
+ALTER TABLE url ADD COLUMN host TEXT NOT NULL DEFAULT '';
+
+UPDATE url SET created_at = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now');
+
+UPDATE url SET host = lower(substr(h, 1, instr(h, '/') - 1))
+FROM (
+	SELECT id AS hid,
+		replace(replace(replace(substr(url, instr(url, '://') + 3), ':', '/'), '?', '/'), '#', '/') || '/' AS h
+	FROM url
+)
+WHERE id = hid;
+
+CREATE INDEX idx_url_created_at_id ON url(created_at, id);
+
+CREATE INDEX idx_url_host ON url(host);
@@ -0,0 +1 @@
+ALTER TABLE url ADD COLUMN owner TEXT NOT NULL DEFAULT '';
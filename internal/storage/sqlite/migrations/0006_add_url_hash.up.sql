@@ -0,0 +1,7 @@
+-- SQLite has no built-in SHA-256, so links saved before this migration keep
+-- an empty hash and are never reused by deduplication.
+ALTER TABLE url ADD COLUMN url_hash TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE url ADD COLUMN generated INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_url_owner_url_hash ON url(owner, url_hash);
@@ -0,0 +1,3 @@
+ALTER TABLE url DROP COLUMN forward_path;
+
+ALTER TABLE url DROP COLUMN forward_query;
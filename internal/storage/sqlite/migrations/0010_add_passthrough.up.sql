@@ -0,0 +1,3 @@
+ALTER TABLE url ADD COLUMN forward_query INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE url ADD COLUMN forward_path INTEGER NOT NULL DEFAULT 0;
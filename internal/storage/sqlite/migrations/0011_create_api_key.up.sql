@@ -0,0 +1,10 @@
+CREATE TABLE api_key(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
@@ -0,0 +1,7 @@
+ALTER TABLE api_key DROP COLUMN user_id;
+
+DROP INDEX IF EXISTS idx_url_owner_id;
+
+ALTER TABLE url DROP COLUMN owner_id;
+
+DROP TABLE IF EXISTS users;
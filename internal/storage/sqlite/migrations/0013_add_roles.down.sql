@@ -0,0 +1,7 @@
+ALTER TABLE api_key DROP COLUMN role;
+
+ALTER TABLE users ADD COLUMN admin INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users SET admin = 1 WHERE role = 'admin';
+
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,9 @@
+-- Roles replace the admin flag, users that were not admins keep creating and
+-- changing their own links as editors.
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'editor';
+
+UPDATE users SET role = 'admin' WHERE admin <> 0;
+
+ALTER TABLE users DROP COLUMN admin;
+
+ALTER TABLE api_key ADD COLUMN role TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS audit_log(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at TIMESTAMP NOT NULL,
+	actor TEXT NOT NULL,
+	actor_id INTEGER NOT NULL DEFAULT 0,
+	action TEXT NOT NULL,
+	alias TEXT NOT NULL DEFAULT '',
+	before_value TEXT,
+	after_value TEXT,
+	request_id TEXT NOT NULL DEFAULT '',
+	client_ip TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_alias ON audit_log(alias);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor);
+
+-- The audit log is append-only, entries can neither be changed nor removed.
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
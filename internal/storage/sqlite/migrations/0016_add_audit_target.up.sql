@@ -0,0 +1,3 @@
+-- Entries of users and API keys refer to them by id, as links are referred to
+-- by alias.
+ALTER TABLE audit_log ADD COLUMN target_id INTEGER NOT NULL DEFAULT 0;
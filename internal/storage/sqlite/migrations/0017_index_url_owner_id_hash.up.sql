@@ -0,0 +1,5 @@
+-- Deduplication looks links up by owner_id since links are owned by users,
+-- the index on the owner name no longer serves it.
+DROP INDEX IF EXISTS idx_url_owner_url_hash;
+
+CREATE INDEX IF NOT EXISTS idx_url_owner_id_url_hash ON url(owner_id, url_hash);
@@ -0,0 +1,1050 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/migrate"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type Storage struct {
+	db *sql.DB
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func New(storagePath string) (*Storage, error) {
+	const op = "storage.sqlite.New"
+
+	db, err := sql.Open("sqlite", withPragmas(storagePath))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = db.Ping()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Migrator returns a schema migrator bound to the storage database.
+func (s *Storage) Migrator() (*migrate.Migrator, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(s.db, migrate.SQLite, sub)
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+func (s *Storage) SaveURL(link storage.Link, audit ...storage.AuditEntry) error {
+	const op = "storage.sqlite.SaveURL"
+
+	return s.inTx(op, func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO url(url, alias, expires_at, host, owner, created_at, url_hash, generated, canonical_url, password_hash, redirect_status, forward_query, forward_path, owner_id) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			link.URL, link.Alias, nullTime(link.ExpiresAt), storage.HostOf(link.Canonical()), link.Owner, time.Now().UTC(), storage.HashURL(link.Canonical()), link.Generated, link.Canonical(), link.PasswordHash, link.StatusCode(), link.ForwardQuery, link.ForwardPath, nullID(link.OwnerID))
+		if err != nil {
+			if isUniqueViolation(err) {
+				return fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		return appendAudit(tx, audit...)
+	})
+}
+
+// SaveURLs stores all links in one transaction. When any link conflicts with
+// an existing alias nothing is stored and the per-link errors are returned.
+func (s *Storage) SaveURLs(links []storage.Link, audit []storage.AuditEntry) ([]error, error) {
+	const op = "storage.sqlite.SaveURLs"
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.Prepare("INSERT INTO url(url, alias, expires_at, host, owner, created_at, url_hash, generated, canonical_url, password_hash, redirect_status, forward_query, forward_path, owner_id) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (alias) DO NOTHING")
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	errs := make([]error, len(links))
+	failed := false
+
+	for i, link := range links {
+		res, err := stmt.Exec(link.URL, link.Alias, nullTime(link.ExpiresAt), storage.HostOf(link.Canonical()), link.Owner, time.Now().UTC(), storage.HashURL(link.Canonical()), link.Generated, link.Canonical(), link.PasswordHash, link.StatusCode(), link.ForwardQuery, link.ForwardPath, nullID(link.OwnerID))
+		if err != nil {
+			return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("%s: get affected rows: %w", op, err)
+		}
+
+		if rowsAffected == 0 {
+			errs[i] = storage.ErrURLExists
+			failed = true
+		}
+	}
+
+	if failed {
+		return errs, nil
+	}
+
+	if err := appendAudit(tx, audit...); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return errs, nil
+}
+
+func (s *Storage) GetURL(alias string) (string, error) {
+	const op = "storage.sqlite.GetURL"
+
+	var link storage.Link
+	var expiresAt sql.NullTime
+
+	err := s.db.QueryRow("SELECT url, expires_at FROM url WHERE alias = ?", alias).Scan(&link.URL, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+
+	if link.Expired(time.Now()) {
+		return "", storage.ErrURLExpired
+	}
+
+	return link.URL, nil
+}
+
+// ResolveLink returns the fields needed to follow a link. Expired links yield
+// storage.ErrURLExpired.
+func (s *Storage) ResolveLink(alias string) (storage.Link, error) {
+	const op = "storage.sqlite.ResolveLink"
+
+	var link storage.Link
+	var expiresAt sql.NullTime
+
+	err := s.db.QueryRow("SELECT id, alias, url, expires_at, password_hash, redirect_status, forward_query, forward_path FROM url WHERE alias = ?", alias).Scan(
+		&link.ID, &link.Alias, &link.URL, &expiresAt, &link.PasswordHash, &link.RedirectStatus, &link.ForwardQuery, &link.ForwardPath,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+
+	if link.Expired(time.Now()) {
+		return storage.Link{}, storage.ErrURLExpired
+	}
+
+	return link, nil
+}
+
+// GetLink returns the link with its total click count. Unlike GetURL it
+// returns expired links as well.
+func (s *Storage) GetLink(alias string) (storage.Link, error) {
+	const op = "storage.sqlite.GetLink"
+
+	var link storage.Link
+	var expiresAt sql.NullTime
+
+	err := s.db.QueryRow(`
+		SELECT id, alias, url, canonical_url, owner, COALESCE(owner_id, 0), created_at, expires_at, generated, password_hash, redirect_status,
+			forward_query, forward_path, (SELECT COUNT(*) FROM click WHERE click.url_id = url.id)
+		FROM url WHERE alias = ?
+	`, alias).Scan(
+		&link.ID, &link.Alias, &link.URL, &link.CanonicalURL, &link.Owner, &link.OwnerID, &link.CreatedAt, &expiresAt, &link.Generated, &link.PasswordHash, &link.RedirectStatus,
+		&link.ForwardQuery, &link.ForwardPath, &link.Clicks,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+
+	return link, nil
+}
+
+// FindGeneratedLink returns the oldest link with a generated alias that ownerID
+// created for canonicalURL and that never expires.
+func (s *Storage) FindGeneratedLink(ownerID int64, canonicalURL string) (storage.Link, error) {
+	const op = "storage.sqlite.FindGeneratedLink"
+
+	link := storage.Link{Generated: true}
+
+	err := s.db.QueryRow(`
+		SELECT id, alias, url, canonical_url, owner, owner_id, created_at, redirect_status, forward_query, forward_path
+		FROM url
+		WHERE owner_id = ? AND url_hash = ? AND canonical_url = ? AND generated AND expires_at IS NULL AND password_hash = ''
+		ORDER BY created_at, id
+		LIMIT 1
+	`, ownerID, storage.HashURL(canonicalURL), canonicalURL).Scan(
+		&link.ID, &link.Alias, &link.URL, &link.CanonicalURL, &link.Owner, &link.OwnerID, &link.CreatedAt, &link.RedirectStatus,
+		&link.ForwardQuery, &link.ForwardPath,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// ListLinks returns a page of links ordered by creation time, using keyset
+// pagination on (created_at, id).
+func (s *Storage) ListLinks(params storage.ListParams) ([]storage.Link, error) {
+	const op = "storage.sqlite.ListLinks"
+
+	var where []string
+	var args []any
+
+	arg := func(value any) string {
+		args = append(args, value)
+		return "?"
+	}
+
+	if params.OwnerID != 0 {
+		where = append(where, "owner_id = "+arg(params.OwnerID))
+	}
+	if params.AliasPrefix != "" {
+		where = append(where, fmt.Sprintf(
+			"substr(alias, 1, %s) = %s",
+			arg(utf8.RuneCountInString(params.AliasPrefix)), arg(params.AliasPrefix),
+		))
+	}
+	if params.URLContains != "" {
+		where = append(where, fmt.Sprintf("instr(lower(url), lower(%s)) > 0", arg(params.URLContains)))
+	}
+	if params.Host != "" {
+		where = append(where, "host = "+arg(strings.ToLower(params.Host)))
+	}
+
+	order, cmp := "ASC", ">"
+	if params.Order == storage.SortDesc {
+		order, cmp = "DESC", "<"
+	}
+
+	if params.After != nil {
+		where = append(where, fmt.Sprintf(
+			"(created_at, id) %s (%s, %s)",
+			cmp, arg(params.After.CreatedAt.UTC()), arg(params.After.ID),
+		))
+	}
+
+	query := "SELECT id, alias, url, canonical_url, owner, COALESCE(owner_id, 0), created_at, expires_at FROM url"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY created_at %s, id %s LIMIT %s", order, order, arg(params.Limit))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var links []storage.Link
+
+	for rows.Next() {
+		var link storage.Link
+		var expiresAt sql.NullTime
+
+		if err := rows.Scan(&link.ID, &link.Alias, &link.URL, &link.CanonicalURL, &link.Owner, &link.OwnerID, &link.CreatedAt, &expiresAt); err != nil {
+			return nil, fmt.Errorf("%s: scan row: %w", op, err)
+		}
+
+		if expiresAt.Valid {
+			link.ExpiresAt = &expiresAt.Time
+		}
+
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: iterate rows: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// UpdateURL changes the mutable fields of the link in a single statement.
+func (s *Storage) UpdateURL(alias string, ownerID int64, update storage.LinkUpdate, audit ...storage.AuditEntry) error {
+	const op = "storage.sqlite.UpdateURL"
+
+	var sets []string
+	var args []any
+
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, column+" = "+"?")
+	}
+
+	if update.URL != nil {
+		set("url", *update.URL)
+		canonical := *update.URL
+		if update.CanonicalURL != nil {
+			canonical = *update.CanonicalURL
+		}
+
+		set("canonical_url", canonical)
+		set("host", storage.HostOf(canonical))
+		set("url_hash", storage.HashURL(canonical))
+	}
+	if update.ClearExpiry {
+		sets = append(sets, "expires_at = NULL")
+	} else if update.ExpiresAt != nil {
+		set("expires_at", update.ExpiresAt.UTC())
+	}
+
+	if len(sets) == 0 {
+		return fmt.Errorf("%s: nothing to update", op)
+	}
+
+	args = append(args, alias, ownerID, ownerID)
+
+	return s.inTx(op, func(tx *sql.Tx) error {
+		before, err := linkForUpdate(tx, alias, ownerID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return notFoundOrNotOwned(tx, alias, ownerID)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		res, err := tx.Exec(
+			"UPDATE url SET "+strings.Join(sets, ", ")+" WHERE alias = ? AND (? = 0 OR owner_id = ?)",
+			args...,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("%s: get affected rows: %w", op, err)
+		}
+
+		if rowsAffected == 0 {
+			return notFoundOrNotOwned(tx, alias, ownerID)
+		}
+
+		entries, err := storage.Changing(audit, before, update.Apply(before))
+		if err != nil {
+			return fmt.Errorf("%s: encode audit entries: %w", op, err)
+		}
+
+		return appendAudit(tx, entries...)
+	})
+}
+
+// linkForUpdate reads the audited state of the link that UpdateURL changes in
+// tx, sql.ErrNoRows when alias does not exist or is not owned by ownerID.
+func linkForUpdate(tx *sql.Tx, alias string, ownerID int64) (storage.Link, error) {
+	var link storage.Link
+	var expiresAt sql.NullTime
+
+	err := tx.QueryRow(`
+		SELECT alias, url, canonical_url, owner, expires_at, password_hash, redirect_status, forward_query, forward_path
+		FROM url WHERE alias = ? AND (? = 0 OR owner_id = ?)
+	`, alias, ownerID, ownerID).Scan(
+		&link.Alias, &link.URL, &link.CanonicalURL, &link.Owner, &expiresAt, &link.PasswordHash, &link.RedirectStatus,
+		&link.ForwardQuery, &link.ForwardPath,
+	)
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+
+	return link, nil
+}
+
+func (s *Storage) DeleteAlias(alias string, ownerID int64, audit ...storage.AuditEntry) error {
+	const op = "storage.sqlite.DeleteAlias"
+
+	return s.inTx(op, func(tx *sql.Tx) error {
+		res, err := tx.Exec("DELETE FROM url WHERE alias = ? AND (? = 0 OR owner_id = ?)", alias, ownerID, ownerID)
+		if err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("%s: get affected rows: %w", op, err)
+		}
+
+		if rowsAffected == 0 {
+			return notFoundOrNotOwned(tx, alias, ownerID)
+		}
+
+		return appendAudit(tx, audit...)
+	})
+}
+
+// notFoundOrNotOwned tells why a statement scoped to ownerID matched no link
+// with the given alias.
+func notFoundOrNotOwned(tx *sql.Tx, alias string, ownerID int64) error {
+	const op = "storage.sqlite.notFoundOrNotOwned"
+
+	if ownerID == 0 {
+		return storage.ErrURLNotFound
+	}
+
+	var exists bool
+
+	err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM url WHERE alias = ?)", alias).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if exists {
+		return storage.ErrNotOwner
+	}
+
+	return storage.ErrURLNotFound
+}
+
+// DeleteExpired removes up to limit links that expired before the given time
+// and appends audit for each of them, with Alias set to the removed alias.
+func (s *Storage) DeleteExpired(before time.Time, limit int, audit storage.AuditEntry) (int64, error) {
+	const op = "storage.sqlite.DeleteExpired"
+
+	var links []storage.Link
+
+	err := s.inTx(op, func(tx *sql.Tx) error {
+		rows, err := tx.Query(`
+			DELETE FROM url WHERE id IN (
+				SELECT id FROM url WHERE expires_at <= ? ORDER BY expires_at LIMIT ?
+			)
+			RETURNING alias, url, owner, expires_at, password_hash, redirect_status, forward_query, forward_path
+		`, before.UTC(), limit)
+		if err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var link storage.Link
+			var expiresAt sql.NullTime
+
+			err := rows.Scan(
+				&link.Alias, &link.URL, &link.Owner, &expiresAt, &link.PasswordHash, &link.RedirectStatus,
+				&link.ForwardQuery, &link.ForwardPath,
+			)
+			if err != nil {
+				return fmt.Errorf("%s: scan row: %w", op, err)
+			}
+
+			if expiresAt.Valid {
+				link.ExpiresAt = &expiresAt.Time
+			}
+
+			links = append(links, link)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("%s: iterate rows: %w", op, err)
+		}
+
+		entries, err := storage.PerLink(audit, links)
+		if err != nil {
+			return fmt.Errorf("%s: encode audit entries: %w", op, err)
+		}
+
+		return appendAudit(tx, entries...)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(links)), nil
+}
+
+// SaveClicks stores the clicks in one transaction, with a multi-row insert per
+// clicksPerInsert clicks. Clicks for aliases that no longer exist are skipped.
+func (s *Storage) SaveClicks(clicks []storage.Click) error {
+	const op = "storage.sqlite.SaveClicks"
+
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	return s.inTx(op, func(tx *sql.Tx) error {
+		for len(clicks) > 0 {
+			n := min(len(clicks), clicksPerInsert)
+			if err := insertClicks(tx, clicks[:n]); err != nil {
+				return err
+			}
+
+			clicks = clicks[n:]
+		}
+
+		return nil
+	})
+}
+
+// clicksPerInsert keeps the parameters of one insertClicks statement, five per
+// click, well below the limit of the database.
+const clicksPerInsert = 1000
+
+func insertClicks(db execer, clicks []storage.Click) error {
+	const op = "storage.sqlite.insertClicks"
+
+	values := make([]string, 0, len(clicks))
+	args := make([]any, 0, len(clicks)*5)
+
+	for _, click := range clicks {
+		values = append(values, "(?, ?, ?, ?, ?)")
+		args = append(args, click.Alias, click.Timestamp.UTC(), click.Referrer, click.UserAgent, click.IP)
+	}
+
+	_, err := db.Exec(`
+		WITH v(alias, clicked_at, referrer, user_agent, ip) AS (VALUES `+strings.Join(values, ", ")+`)
+		INSERT INTO click(url_id, clicked_at, referrer, user_agent, ip)
+		SELECT url.id, v.clicked_at, v.referrer, v.user_agent, v.ip
+		FROM v JOIN url ON url.alias = v.alias
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetStats returns the total click count of the link together with daily
+// counts and the most frequent referrers since the given time. Links outside
+// ownerID are reported as not found.
+func (s *Storage) GetStats(alias string, ownerID int64, since time.Time, topReferrers int) (storage.Stats, error) {
+	const op = "storage.sqlite.GetStats"
+
+	var stats storage.Stats
+	var urlID int64
+
+	err := s.db.QueryRow("SELECT id FROM url WHERE alias = ? AND (? = 0 OR owner_id = ?)", alias, ownerID, ownerID).Scan(&urlID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return stats, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return stats, fmt.Errorf("%s: get url: %w", op, err)
+	}
+
+	err = s.db.QueryRow("SELECT COUNT(*) FROM click WHERE url_id = ?", urlID).Scan(&stats.TotalClicks)
+	if err != nil {
+		return stats, fmt.Errorf("%s: count clicks: %w", op, err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT substr(clicked_at, 1, 10) AS day, COUNT(*)
+		FROM click
+		WHERE url_id = ? AND clicked_at >= ?
+		GROUP BY day
+		ORDER BY day
+	`, urlID, since.UTC())
+	if err != nil {
+		return stats, fmt.Errorf("%s: query clicks per day: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var daily storage.DailyClicks
+		if err := rows.Scan(&daily.Date, &daily.Clicks); err != nil {
+			return stats, fmt.Errorf("%s: scan clicks per day: %w", op, err)
+		}
+
+		stats.ClicksPerDay = append(stats.ClicksPerDay, daily)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("%s: iterate clicks per day: %w", op, err)
+	}
+
+	refRows, err := s.db.Query(`
+		SELECT referrer, COUNT(*) AS clicks
+		FROM click
+		WHERE url_id = ? AND clicked_at >= ? AND referrer <> ''
+		GROUP BY referrer
+		ORDER BY clicks DESC, referrer
+		LIMIT ?
+	`, urlID, since.UTC(), topReferrers)
+	if err != nil {
+		return stats, fmt.Errorf("%s: query top referrers: %w", op, err)
+	}
+	defer func() { _ = refRows.Close() }()
+
+	for refRows.Next() {
+		var ref storage.ReferrerClicks
+		if err := refRows.Scan(&ref.Referrer, &ref.Clicks); err != nil {
+			return stats, fmt.Errorf("%s: scan top referrers: %w", op, err)
+		}
+
+		stats.TopReferrers = append(stats.TopReferrers, ref)
+	}
+	if err := refRows.Err(); err != nil {
+		return stats, fmt.Errorf("%s: iterate top referrers: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// CreateUser stores user and appends the audit entries, with TargetID set
+// to the id of the new user, in the same transaction.
+func (s *Storage) CreateUser(user storage.User, audit ...storage.AuditEntry) (int64, error) {
+	const op = "storage.sqlite.CreateUser"
+
+	var id int64
+
+	err := s.inTx(op, func(tx *sql.Tx) error {
+		res, err := tx.Exec(
+			"INSERT INTO users(name, role, issuer, subject, created_at) VALUES(?, ?, ?, ?, ?)",
+			user.Name, user.Role, user.Issuer, user.Subject, time.Now().UTC(),
+		)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+
+		id, err = res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("%s: get last insert id: %w", op, err)
+		}
+
+		return appendAudit(tx, storage.Targeting(id, audit)...)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetUser(id int64) (storage.User, error) {
+	const op = "storage.sqlite.GetUser"
+
+	user, err := scanUser(s.db.QueryRow("SELECT id, name, role, issuer, subject, created_at FROM users WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.User{}, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return storage.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) GetUserByName(name string) (storage.User, error) {
+	const op = "storage.sqlite.GetUserByName"
+
+	user, err := scanUser(s.db.QueryRow("SELECT id, name, role, issuer, subject, created_at FROM users WHERE name = ?", name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.User{}, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return storage.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// GetUserBySubject returns the SSO user signed in with tokens of issuer for
+// subject.
+func (s *Storage) GetUserBySubject(issuer, subject string) (storage.User, error) {
+	const op = "storage.sqlite.GetUserBySubject"
+
+	user, err := scanUser(s.db.QueryRow("SELECT id, name, role, issuer, subject, created_at FROM users WHERE issuer = ? AND subject = ?", issuer, subject))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.User{}, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return storage.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// ListUsers returns all users in creation order.
+func (s *Storage) ListUsers() ([]storage.User, error) {
+	const op = "storage.sqlite.ListUsers"
+
+	rows, err := s.db.Query("SELECT id, name, role, issuer, subject, created_at FROM users ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	users := []storage.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan row: %w", op, err)
+		}
+
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: iterate rows: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// CreateAPIKey stores key and appends the audit entries, with TargetID set
+// to the id of the new key, in the same transaction.
+func (s *Storage) CreateAPIKey(key storage.APIKey, audit ...storage.AuditEntry) (int64, error) {
+	const op = "storage.sqlite.CreateAPIKey"
+
+	var id int64
+
+	err := s.inTx(op, func(tx *sql.Tx) error {
+		res, err := tx.Exec(
+			"INSERT INTO api_key(name, user_id, role, prefix, hash, created_at, expires_at) VALUES(?, ?, ?, ?, ?, ?, ?)",
+			key.Name, key.UserID, key.Role, key.Prefix, key.Hash, time.Now().UTC(), nullTime(key.ExpiresAt),
+		)
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+
+		id, err = res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("%s: get last insert id: %w", op, err)
+		}
+
+		return appendAudit(tx, storage.Targeting(id, audit)...)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetAPIKey returns the key with the given token hash, including revoked and
+// expired keys.
+func (s *Storage) GetAPIKey(hash string) (storage.APIKey, error) {
+	const op = "storage.sqlite.GetAPIKey"
+
+	row := s.db.QueryRow(`
+		SELECT api_key.id, api_key.name, api_key.role, prefix, hash, api_key.created_at, expires_at, last_used_at, revoked_at,
+			COALESCE(api_key.user_id, 0), COALESCE(users.name, '')
+		FROM api_key LEFT JOIN users ON users.id = api_key.user_id
+		WHERE hash = ?
+	`, hash)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns all keys in creation order.
+func (s *Storage) ListAPIKeys() ([]storage.APIKey, error) {
+	const op = "storage.sqlite.ListAPIKeys"
+
+	rows, err := s.db.Query(`
+		SELECT api_key.id, api_key.name, api_key.role, prefix, hash, api_key.created_at, expires_at, last_used_at, revoked_at,
+			COALESCE(api_key.user_id, 0), COALESCE(users.name, '')
+		FROM api_key LEFT JOIN users ON users.id = api_key.user_id
+		ORDER BY api_key.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := []storage.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan row: %w", op, err)
+		}
+
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: iterate rows: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks the key as revoked at the given time. Revoking a key
+// again keeps the original revocation time.
+func (s *Storage) RevokeAPIKey(id int64, at time.Time, audit ...storage.AuditEntry) error {
+	const op = "storage.sqlite.RevokeAPIKey"
+
+	return s.inTx(op, func(tx *sql.Tx) error {
+		res, err := tx.Exec("UPDATE api_key SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", at.UTC(), id)
+		if err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("%s: get affected rows: %w", op, err)
+		}
+
+		// Revoking a revoked key changes nothing, so it is not audited again.
+		if rowsAffected == 0 {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM api_key WHERE id = ?)", id).Scan(&exists); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if !exists {
+				return storage.ErrAPIKeyNotFound
+			}
+
+			return nil
+		}
+
+		return appendAudit(tx, audit...)
+	})
+}
+
+// TouchAPIKey records the time the key was last used.
+func (s *Storage) TouchAPIKey(id int64, at time.Time) error {
+	const op = "storage.sqlite.TouchAPIKey"
+
+	_, err := s.db.Exec("UPDATE api_key SET last_used_at = ? WHERE id = ?", at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	return nil
+}
+
+// appendAudit records the entries in the audit log through db, which is the
+// transaction of the change they describe.
+func appendAudit(db execer, entries ...storage.AuditEntry) error {
+	const op = "storage.sqlite.appendAudit"
+
+	for _, entry := range entries {
+		_, err := db.Exec(`
+			INSERT INTO audit_log(created_at, actor, actor_id, action, alias, target_id, before_value, after_value, request_id, client_ip)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			time.Now().UTC(), entry.Actor, entry.ActorID, entry.Action, entry.Alias, entry.TargetID,
+			nullString(entry.Before), nullString(entry.After), entry.RequestID, entry.ClientIP,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// inTx runs fn in a transaction, which is committed when fn succeeds and
+// rolled back otherwise.
+func (s *Storage) inTx(op string, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListAudit returns the audit log entries matching filter, newest first.
+func (s *Storage) ListAudit(filter storage.AuditFilter) ([]storage.AuditEntry, error) {
+	const op = "storage.sqlite.ListAudit"
+
+	var where []string
+	var args []any
+
+	arg := func(value any) string {
+		args = append(args, value)
+		return "?"
+	}
+
+	if filter.Actor != "" {
+		where = append(where, "actor = "+arg(filter.Actor))
+	}
+	if filter.Action != "" {
+		where = append(where, "action = "+arg(filter.Action))
+	}
+	if filter.Alias != "" {
+		where = append(where, "alias = "+arg(filter.Alias))
+	}
+	if filter.Since != nil {
+		where = append(where, "created_at >= "+arg(filter.Since.UTC()))
+	}
+	if filter.Until != nil {
+		where = append(where, "created_at < "+arg(filter.Until.UTC()))
+	}
+	if filter.BeforeID != 0 {
+		where = append(where, "id < "+arg(filter.BeforeID))
+	}
+
+	query := `SELECT id, created_at, actor, actor_id, action, alias, target_id, COALESCE(before_value, ''), COALESCE(after_value, ''),
+		request_id, client_ip FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT " + arg(filter.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []storage.AuditEntry
+
+	for rows.Next() {
+		var e storage.AuditEntry
+
+		err := rows.Scan(&e.ID, &e.CreatedAt, &e.Actor, &e.ActorID, &e.Action, &e.Alias, &e.TargetID, &e.Before, &e.After, &e.RequestID, &e.ClientIP)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan row: %w", op, err)
+		}
+
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: iterate rows: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+func scanUser(row interface{ Scan(dest ...any) error }) (storage.User, error) {
+	var user storage.User
+
+	err := row.Scan(&user.ID, &user.Name, &user.Role, &user.Issuer, &user.Subject, &user.CreatedAt)
+
+	return user, err
+}
+
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (storage.APIKey, error) {
+	var key storage.APIKey
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.Name, &key.Role, &key.Prefix, &key.Hash, &key.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt, &key.UserID, &key.User)
+	if err != nil {
+		return storage.APIKey{}, err
+	}
+
+	key.ExpiresAt = timePtr(expiresAt)
+	key.LastUsedAt = timePtr(lastUsedAt)
+	key.RevokedAt = timePtr(revokedAt)
+
+	return key, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// withPragmas configures every pooled connection. SQLite keeps foreign keys
+// off by default and ON DELETE CASCADE would be ignored. WAL lets readers run
+// alongside the single writer, busy_timeout makes a writer wait for the lock
+// instead of failing with SQLITE_BUSY, and immediate transactions take the
+// write lock up front so that wait also covers transactions that read first.
+func withPragmas(storagePath string) string {
+	sep := "?"
+	if strings.Contains(storagePath, "?") {
+		sep = "&"
+	}
+
+	return storagePath + sep + "_pragma=foreign_keys(1)" +
+		"&_pragma=journal_mode(WAL)" +
+		"&_pragma=busy_timeout(5000)" +
+		"&_txlock=immediate"
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
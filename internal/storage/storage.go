@@ -1,11 +1,412 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 var (
 	ErrURLNotFound  = errors.New("url not found")
 	ErrURLExists    = errors.New("url exists")
 	ErrDBConnection = errors.New("failed to connect to database")
+
+	// ErrNotOwner is returned when an operation scoped to a user targets a
+	// link that exists but is owned by someone else.
+	ErrNotOwner = errors.New("link owned by another user")
+
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user exists")
+
+	ErrAPIKeyNotFound = errors.New("api key not found")
+
+	// ErrURLExpired is returned for links past their expiry time. It wraps
+	// ErrURLNotFound so callers that do not care about expiry treat both alike.
+	ErrURLExpired = fmt.Errorf("%w: expired", ErrURLNotFound)
+)
+
+// Link is a short link as stored by the storage backends.
+type Link struct {
+	ID    int64
+	Alias string
+	URL   string
+	// CanonicalURL is the normalized form of URL used for host filtering and
+	// deduplication. Empty means URL is already canonical.
+	CanonicalURL string
+	// Owner is the name of the owning user, OwnerID its id. Links created
+	// before users were introduced may have no owner, only admins can change
+	// those.
+	Owner     string
+	OwnerID   int64
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+
+	// Generated is set for links whose alias was generated by the service
+	// rather than chosen by the caller.
+	Generated bool
+
+	// PasswordHash is the bcrypt hash of the password guarding the link,
+	// empty for public links.
+	PasswordHash string
+
+	// RedirectStatus is the 3xx status code the link redirects with. Zero
+	// means 302 Found.
+	RedirectStatus int
+
+	// ForwardQuery and ForwardPath pass the query string and the path below
+	// the alias of a visit on to the destination.
+	ForwardQuery bool
+	ForwardPath  bool
+
+	// Clicks is the total number of recorded clicks, only GetLink fills it.
+	Clicks int64
+}
+
+// Canonical returns CanonicalURL, or URL when no canonical form was set.
+func (l Link) Canonical() string {
+	if l.CanonicalURL != "" {
+		return l.CanonicalURL
+	}
+	return l.URL
+}
+
+// StatusCode returns RedirectStatus, or 302 Found when none was set.
+func (l Link) StatusCode() int {
+	if l.RedirectStatus != 0 {
+		return l.RedirectStatus
+	}
+	return http.StatusFound
+}
+
+// OwnedBy reports whether the link is within an operation scoped to ownerID,
+// a zero ownerID covers every link.
+func (l Link) OwnedBy(ownerID int64) bool {
+	return ownerID == 0 || l.OwnerID == ownerID
+}
+
+// Expired reports whether the link is past its expiry time at now.
+func (l Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// HostOf returns the lowercased host of a destination URL, or an empty
+// string when the URL cannot be parsed.
+func HostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// HashURL returns the hex SHA-256 of a destination URL. Backends index it to
+// find links by destination without indexing arbitrarily long URLs.
+func HashURL(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// SortOrder is the creation time order of listed links.
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
 )
+
+// Cursor is the keyset position of the last link of a listed page.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// ListParams filters and paginates ListLinks. Empty filters match everything.
+type ListParams struct {
+	OwnerID     int64
+	AliasPrefix string
+	URLContains string
+	Host        string
+	Order       SortOrder
+	After       *Cursor
+	Limit       int
+}
+
+// LinkUpdate holds the mutable fields of a link. Nil fields are left
+// unchanged, ClearExpiry removes the expiry time.
+type LinkUpdate struct {
+	URL *string
+	// CanonicalURL is the normalized form of URL, defaults to URL.
+	CanonicalURL *string
+	ExpiresAt    *time.Time
+	ClearExpiry  bool
+}
+
+// Empty reports whether the update changes nothing.
+func (u LinkUpdate) Empty() bool {
+	return u.URL == nil && u.ExpiresAt == nil && !u.ClearExpiry
+}
+
+// Apply returns link as changed by the update.
+func (u LinkUpdate) Apply(link Link) Link {
+	if u.URL != nil {
+		link.URL = *u.URL
+		link.CanonicalURL = *u.URL
+		if u.CanonicalURL != nil {
+			link.CanonicalURL = *u.CanonicalURL
+		}
+	}
+	if u.ClearExpiry {
+		link.ExpiresAt = nil
+	} else if u.ExpiresAt != nil {
+		link.ExpiresAt = u.ExpiresAt
+	}
+
+	return link
+}
+
+// Click is a single successful redirect through a short link.
+type Click struct {
+	Alias     string
+	Timestamp time.Time
+	Referrer  string
+	UserAgent string
+	IP        string
+}
+
+// Stats aggregates the clicks recorded for a link.
+type Stats struct {
+	TotalClicks  int64
+	ClicksPerDay []DailyClicks
+	TopReferrers []ReferrerClicks
+}
+
+type DailyClicks struct {
+	Date   string
+	Clicks int64
+}
+
+type ReferrerClicks struct {
+	Referrer string
+	Clicks   int64
+}
+
+// User is an account links and API keys belong to. Role names the rbac role
+// that decides what the user may do.
+type User struct {
+	ID   int64
+	Name string
+	Role string
+	// Issuer and Subject identify the SSO account of users created on their
+	// first sign-in with a token, both are empty for local users.
+	Issuer    string
+	Subject   string
+	CreatedAt time.Time
+}
+
+// SSO reports whether the user was created by signing in with SSO.
+func (u User) SSO() bool {
+	return u.Subject != ""
+}
+
+// APIKey is a bearer token issued to an API client. Only the SHA-256 of the
+// token is stored, the token itself is shown once when the key is created.
+type APIKey struct {
+	ID   int64
+	Name string
+	// UserID is the user the key authenticates as, User its name.
+	UserID int64
+	User   string
+	// Role restricts the key to a less privileged role than the one of its
+	// user, empty keys act with the role of the user.
+	Role string
+	// Prefix is the start of the token, kept to tell keys apart in listings.
+	Prefix     string
+	Hash       string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// Active reports whether the key may be used at now, that is it is neither
+// revoked nor expired.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// AuditEntry is a mutating operation as recorded in the append-only audit log.
+type AuditEntry struct {
+	ID        int64
+	CreatedAt time.Time
+	// Actor is the name of the user the operation was made by, ActorID its id.
+	Actor   string
+	ActorID int64
+	Action  string
+	// Alias is the link the operation changed, empty for users and API keys.
+	Alias string
+	// TargetID is the id of the user or API key the operation changed, zero
+	// for links.
+	TargetID int64
+	// Before and After are JSON documents of the changed object, empty when it
+	// did not exist before or after the operation.
+	Before    string
+	After     string
+	RequestID string
+	ClientIP  string
+}
+
+// Actions recorded in the audit log.
+const (
+	ActionLinkCreate = "link.create"
+	ActionLinkUpdate = "link.update"
+	ActionLinkDelete = "link.delete"
+	ActionLinkPurge  = "link.purge"
+	ActionKeyCreate  = "key.create"
+	ActionKeyRevoke  = "key.revoke"
+	ActionUserCreate = "user.create"
+)
+
+// SystemActor is the actor of changes the service makes on its own, such as
+// purging expired links.
+const SystemActor = "system"
+
+// LinkState is the state of a link as recorded in the audit log. It leaves
+// out the password hash, only whether the link is protected.
+type LinkState struct {
+	Alias             string     `json:"alias"`
+	URL               string     `json:"url"`
+	CanonicalURL      string     `json:"canonical_url,omitempty"`
+	Owner             string     `json:"owner,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	PasswordProtected bool       `json:"password_protected,omitempty"`
+	RedirectStatus    int        `json:"redirect_status,omitempty"`
+	ForwardQuery      bool       `json:"forward_query,omitempty"`
+	ForwardPath       bool       `json:"forward_path,omitempty"`
+}
+
+// AuditState returns the audited state of the link.
+func (l Link) AuditState() *LinkState {
+	return &LinkState{
+		Alias:             l.Alias,
+		URL:               l.URL,
+		CanonicalURL:      l.CanonicalURL,
+		Owner:             l.Owner,
+		ExpiresAt:         l.ExpiresAt,
+		PasswordProtected: l.PasswordHash != "",
+		RedirectStatus:    l.RedirectStatus,
+		ForwardQuery:      l.ForwardQuery,
+		ForwardPath:       l.ForwardPath,
+	}
+}
+
+// Targeting returns a copy of entries with TargetID set to id. Backends
+// apply it to the entries of operations that create the object, whose id is
+// not known before.
+func Targeting(id int64, entries []AuditEntry) []AuditEntry {
+	targeted := make([]AuditEntry, len(entries))
+	for i, entry := range entries {
+		entry.TargetID = id
+		targeted[i] = entry
+	}
+
+	return targeted
+}
+
+// Changing returns a copy of entries with Before and After set to the audited
+// states of before and after. Backends apply it to updates, whose states are
+// read in the same transaction as the change.
+func Changing(entries []AuditEntry, before, after Link) ([]AuditEntry, error) {
+	beforeState, err := json.Marshal(before.AuditState())
+	if err != nil {
+		return nil, err
+	}
+
+	afterState, err := json.Marshal(after.AuditState())
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make([]AuditEntry, len(entries))
+	for i, entry := range entries {
+		entry.Before = string(beforeState)
+		entry.After = string(afterState)
+		changed[i] = entry
+	}
+
+	return changed, nil
+}
+
+// PerLink returns a copy of entry for each of links with Alias set to its
+// alias and Before to its audited state. Backends apply it to operations
+// removing several links at once.
+func PerLink(entry AuditEntry, links []Link) ([]AuditEntry, error) {
+	entries := make([]AuditEntry, len(links))
+	for i, link := range links {
+		before, err := json.Marshal(link.AuditState())
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Alias = link.Alias
+		entry.Before = string(before)
+		entries[i] = entry
+	}
+
+	return entries, nil
+}
+
+// AuditFilter filters and paginates ListAudit, which lists the newest entries
+// first. Empty filters match everything.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Alias  string
+	Since  *time.Time
+	Until  *time.Time
+	// BeforeID continues a listing after its last entry, which had that id.
+	BeforeID int64
+	Limit    int
+}
+
+// Storage is implemented by every link storage backend.
+//
+// The ownerID of DeleteAlias, UpdateURL, GetStats and ListParams restricts
+// them to the links of that user, zero means any link.
+//
+// Operations taking audit entries append them to the audit log in the same
+// transaction as the change, so neither is stored without the other.
+// UpdateURL sets their Before and After to the link as read in that
+// transaction and as changed by the update.
+type Storage interface {
+	SaveURL(link Link, audit ...AuditEntry) error
+	SaveURLs(links []Link, audit []AuditEntry) ([]error, error)
+	GetURL(alias string) (string, error)
+	GetLink(alias string) (Link, error)
+	ResolveLink(alias string) (Link, error)
+	FindGeneratedLink(ownerID int64, canonicalURL string) (Link, error)
+	ListLinks(params ListParams) ([]Link, error)
+	UpdateURL(alias string, ownerID int64, update LinkUpdate, audit ...AuditEntry) error
+	DeleteAlias(alias string, ownerID int64, audit ...AuditEntry) error
+	DeleteExpired(before time.Time, limit int, audit AuditEntry) (int64, error)
+	SaveClicks(clicks []Click) error
+	GetStats(alias string, ownerID int64, since time.Time, topReferrers int) (Stats, error)
+	CreateUser(user User, audit ...AuditEntry) (int64, error)
+	GetUser(id int64) (User, error)
+	GetUserByName(name string) (User, error)
+	GetUserBySubject(issuer, subject string) (User, error)
+	ListUsers() ([]User, error)
+	CreateAPIKey(key APIKey, audit ...AuditEntry) (int64, error)
+	GetAPIKey(hash string) (APIKey, error)
+	ListAPIKeys() ([]APIKey, error)
+	RevokeAPIKey(id int64, at time.Time, audit ...AuditEntry) error
+	TouchAPIKey(id int64, at time.Time) error
+	ListAudit(filter AuditFilter) ([]AuditEntry, error)
+	Close() error
+}